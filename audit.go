@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditRecord is a line of the audit log, one per request which reached the database.
+type auditRecord struct {
+	Time    string   `json:"time"`
+	Key     string   `json:"key"`
+	Session string   `json:"session"`
+	User    string   `json:"user"`
+	Query   string   `json:"query,omitempty"`
+	SQL     string   `json:"sql,omitempty"`
+	Binds   []string `json:"binds,omitempty"`
+	Rows    int      `json:"rows"`
+	Elapsed float64  `json:"elapsed_ms"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// keySQL holds the SQL text run by the keys which do not take a query.
+var keySQL = map[string]string{
+	keyPing:       pingQuery,
+	keyPingDetail: pingQuery + "; " + instanceQuery,
+	keyLatency:    pingQuery,
+}
+
+// auditLog appends records to a JSON lines file. When the file would exceed maxSize
+// it is renamed with a .1 suffix, replacing the previous one, and a new file is started.
+type auditLog struct {
+	sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+
+	// closed drops the records of requests still running when the plugin stopped,
+	// rather than opening a file nobody would close.
+	closed bool
+}
+
+// newAuditLog initializes an auditLog. The file is opened with the first record.
+func newAuditLog(path string, maxSize int64) *auditLog {
+	return &auditLog{path: path, maxSize: maxSize}
+}
+
+// write appends a record to the log.
+func (a *auditLog) write(r *auditRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+
+	a.Lock()
+	defer a.Unlock()
+
+	if a.closed {
+		return nil
+	}
+
+	if a.file != nil && a.size > 0 && a.size+int64(len(line)) > a.maxSize {
+		if err = a.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if a.file == nil {
+		if err = a.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+
+	return err
+}
+
+// open opens the log file for appending.
+func (a *auditLog) open() error {
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot open audit log: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("cannot open audit log: %w", err)
+	}
+
+	a.file = f
+	a.size = info.Size()
+
+	return nil
+}
+
+// rotate closes the log file and moves it aside.
+func (a *auditLog) rotate() error {
+	a.file.Close()
+	a.file = nil
+
+	if err := os.Rename(a.path, a.path+".1"); err != nil {
+		return fmt.Errorf("cannot rotate audit log: %w", err)
+	}
+
+	return nil
+}
+
+// close closes the log file.
+func (a *auditLog) close() {
+	a.Lock()
+	defer a.Unlock()
+
+	a.closed = true
+
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+}
+
+// audit records a request which reached the database in a, the audit log the request started with.
+// Failures to write are logged, they do not fail the item.
+func (p *Plugin) audit(a *auditLog, key string, info *requestInfo, user string, params map[string]string,
+	binds []string, elapsed time.Duration, err error) {
+	if a == nil {
+		return
+	}
+
+	r := &auditRecord{
+		Time:    time.Now().Format(time.RFC3339Nano),
+		Key:     key,
+		Session: info.session,
+		User:    user,
+		Query:   info.name,
+		Rows:    info.rows,
+		Elapsed: milliseconds(elapsed),
+	}
+
+	if sql, ok := keySQL[key]; ok {
+		r.SQL = sql
+	} else if info.name == "" {
+		r.SQL = p.redactor.redact(params["Query"])
+	}
+
+	for _, b := range binds {
+		if p.options.AuditMaskBinds == 1 {
+			b = redactedMask
+		}
+
+		r.Binds = append(r.Binds, p.redactor.redact(b))
+	}
+
+	if err != nil {
+		e := newErrorInfo(err, err.Error())
+
+		r.Error = e.Code
+		if r.Error == "" {
+			r.Error = e.Category
+		}
+	}
+
+	if err = a.write(r); err != nil {
+		p.Warningf("%s", err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog_rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a := newAuditLog(path, 300)
+
+	defer a.close()
+
+	for i := 0; i < 3; i++ {
+		if err := a.write(&auditRecord{Key: keyCustomQuery, SQL: strings.Repeat("x", 50)}); err != nil {
+			t.Fatalf("write() error = %v", err)
+		}
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatalf("audit log was not rotated: %v", err)
+	}
+
+	if lines := strings.Count(string(current), "\n") + strings.Count(string(rotated), "\n"); lines != 3 {
+		t.Errorf("audit log holds %d records, want 3", lines)
+	}
+
+	if len(current) > 300 || len(rotated) > 300 {
+		t.Errorf("audit log sizes %d and %d, want at most 300", len(current), len(rotated))
+	}
+}
+
+func TestPlugin_audit(t *testing.T) {
+	var logger captureLogger
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	p := &Plugin{auditLog: newAuditLog(path, 1024*1024)}
+	p.Logger = &logger
+	p.options.AuditMaskBinds = 1
+	p.redactor.add("s3cr3t_pwd")
+
+	defer p.auditLog.close()
+
+	info := &requestInfo{session: "prod", rows: 2}
+	params := map[string]string{"Query": "SELECT * FROM t WHERE pwd = 's3cr3t_pwd' AND id = :1"}
+
+	p.audit(p.auditLog, keyCustomQuery, info, "ZABBIX", params, []string{"42"}, 1500*time.Microsecond,
+		errorInvalidSQL.Wrap(errors.New("ORA-00942: table or view does not exist")))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got auditRecord
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("audit record %q: %v", data, err)
+	}
+
+	got.Time = ""
+
+	want := auditRecord{
+		Key:     keyCustomQuery,
+		Session: "prod",
+		User:    "ZABBIX",
+		SQL:     "SELECT * FROM t WHERE pwd = '******' AND id = :1",
+		Binds:   []string{redactedMask},
+		Rows:    2,
+		Elapsed: 1.5,
+		Error:   "ORA-00942",
+	}
+
+	if gotJSON, wantJSON := mustJSON(t, got), mustJSON(t, want); gotJSON != wantJSON {
+		t.Errorf("audit record = %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+func TestPlugin_auditCategory(t *testing.T) {
+	var logger captureLogger
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	p := &Plugin{}
+	p.Logger = &logger
+
+	a := newAuditLog(path, 1024*1024)
+	info := &requestInfo{session: "prod"}
+
+	p.audit(a, keyCustomQuery, info, "ZABBIX", nil, nil, time.Second,
+		errorQueryTimeout.Wrap(errors.New("call timeout of 1s exceeded")))
+
+	a.close()
+
+	// A request finishing after the plugin stopped is not recorded.
+	p.audit(a, keyCustomQuery, info, "ZABBIX", nil, nil, time.Second, nil)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got auditRecord
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("audit log %q, want a single record: %v", data, err)
+	}
+
+	if got.Error != "timeout" {
+		t.Errorf("audit record error = %q, want timeout", got.Error)
+	}
+}
+
+func TestPlugin_auditKeySQL(t *testing.T) {
+	var logger captureLogger
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	p := &Plugin{}
+	p.Logger = &logger
+
+	a := newAuditLog(path, 1024*1024)
+	info := &requestInfo{session: "prod"}
+
+	p.audit(a, keyLatency, info, "ZABBIX", map[string]string{"Samples": "5"}, nil, time.Second, nil)
+	p.audit(a, keyPingDetail, info, "ZABBIX", nil, nil, time.Second, nil)
+	a.close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"SELECT 1 FROM DUAL", "SELECT 1 FROM DUAL; " + instanceQuery}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	if len(lines) != len(want) {
+		t.Fatalf("audit log holds %d records, want %d", len(lines), len(want))
+	}
+
+	for i, line := range lines {
+		var got auditRecord
+		if err = json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("audit record %q: %v", line, err)
+		}
+
+		if got.SQL != want[i] {
+			t.Errorf("audit record of %s SQL = %q, want %q", got.Key, got.SQL, want[i])
+		}
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
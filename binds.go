@@ -0,0 +1,273 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godror/godror"
+)
+
+// Bind type prefixes of query arguments. Arguments without a prefix are bound as strings.
+const (
+	bindNull   = "null"
+	bindString = "str:"
+	bindInt    = "int:"
+	bindNumber = "num:"
+	bindDate   = "date:"
+	bindTime   = "ts:"
+)
+
+const bindDateLayout = "2006-01-02"
+
+// bindTimeLayouts are the accepted formats of ts: arguments without a zone.
+var bindTimeLayouts = []string{"2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"}
+
+// Conversions of date: and ts: arguments bound as text, with the layouts the text is formatted with.
+const (
+	toDate      = "TO_DATE(%s, 'YYYY-MM-DD')"
+	toTimestamp = "TO_TIMESTAMP(%s, 'YYYY-MM-DD HH24:MI:SS.FF9')"
+
+	toTimestampLayout = "2006-01-02 15:04:05.000000000"
+)
+
+// convertedBind is an argument bound as text and converted by the statement itself.
+// godror binds time.Time as TIMESTAMP WITH TIME ZONE. Compared to a DATE or TIMESTAMP column, that makes Oracle
+// convert the column rather than the argument, and an index on the column cannot be used.
+type convertedBind struct {
+	text string
+
+	// conversion wraps the placeholder, e.g. TO_DATE(%s, 'YYYY-MM-DD').
+	conversion string
+}
+
+// numberRgx matches a decimal number Oracle reads without a format model, e.g. -1.5 or 2.5E-3.
+var numberRgx = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+// namedBindRgx matches a named argument: :name=value.
+var namedBindRgx = regexp.MustCompile(`^:([A-Za-z][A-Za-z0-9_$#]*)=(.*)$`)
+
+// parseBinds converts key arguments to query arguments. Without typed they are all bound as strings by position,
+// like they always were. A query declaring typed_binds gets them converted, so Oracle compares them
+// without implicit conversions: int:5, num:1.5, date:2020-10-25, ts:2020-10-25T10:00:00, null and str:text
+// for a string starting with a prefix. An argument written as :name=value is bound to the :name placeholder.
+func parseBinds(args []string, typed bool) ([]interface{}, error) {
+	binds := make([]interface{}, len(args))
+
+	if !typed {
+		for i, arg := range args {
+			binds[i] = arg
+		}
+
+		return binds, nil
+	}
+
+	named := 0
+
+	for i, arg := range args {
+		name := ""
+		if m := namedBindRgx.FindStringSubmatch(arg); m != nil {
+			name, arg = m[1], m[2]
+			named++
+		}
+
+		value, err := parseBind(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+
+		if name != "" {
+			value = sql.Named(name, value)
+		}
+
+		binds[i] = value
+	}
+
+	// The driver would only fail when executing the query, with a message not telling which argument is wrong.
+	if named > 0 && named < len(args) {
+		return nil, errors.New("positional and named arguments cannot be mixed")
+	}
+
+	return binds, nil
+}
+
+// parseBind converts a single argument according to its type prefix.
+func parseBind(arg string) (interface{}, error) {
+	switch {
+	case arg == bindNull:
+		return nil, nil
+
+	case strings.HasPrefix(arg, bindString):
+		return strings.TrimPrefix(arg, bindString), nil
+
+	case strings.HasPrefix(arg, bindInt):
+		v, err := strconv.ParseInt(strings.TrimPrefix(arg, bindInt), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", arg)
+		}
+
+		return v, nil
+
+	case strings.HasPrefix(arg, bindNumber):
+		// Bound as text, so Oracle gets every digit: a float64 keeps only 15-17 of its up to 38.
+		value := strings.TrimPrefix(arg, bindNumber)
+		if !numberRgx.MatchString(value) {
+			return nil, fmt.Errorf("invalid number %q", arg)
+		}
+
+		return godror.Number(value), nil
+
+	case strings.HasPrefix(arg, bindDate):
+		v, err := time.Parse(bindDateLayout, strings.TrimPrefix(arg, bindDate))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, want YYYY-MM-DD", arg)
+		}
+
+		return convertedBind{text: v.Format(bindDateLayout), conversion: toDate}, nil
+
+	case strings.HasPrefix(arg, bindTime):
+		value := strings.TrimPrefix(arg, bindTime)
+
+		// A timestamp with a zone can only be compared as TIMESTAMP WITH TIME ZONE.
+		if v, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return v, nil
+		}
+
+		for _, layout := range bindTimeLayouts {
+			if v, err := time.Parse(layout, value); err == nil {
+				return convertedBind{text: v.Format(toTimestampLayout), conversion: toTimestamp}, nil
+			}
+		}
+
+		return nil, fmt.Errorf("invalid timestamp %q, want YYYY-MM-DDThh:mm:ss[.fraction][zone]", arg)
+	}
+
+	return arg, nil
+}
+
+// convertBinds wraps the placeholders of converted arguments into their conversions and binds their text instead.
+// Positional arguments belong to the placeholders in the order they appear, named ones to the placeholders of the name.
+func convertBinds(query string, binds []interface{}) (string, []interface{}) {
+	var converted bool
+
+	named := make(map[string]interface{})
+
+	for _, b := range binds {
+		if arg, ok := b.(sql.NamedArg); ok {
+			named[strings.ToUpper(arg.Name)] = arg.Value
+			b = arg.Value
+		}
+
+		if _, ok := b.(convertedBind); ok {
+			converted = true
+		}
+	}
+
+	if !converted {
+		return query, binds
+	}
+
+	var (
+		sb       strings.Builder
+		last     int
+		position int
+	)
+
+	for _, p := range findPlaceholders(query) {
+		var arg interface{}
+
+		switch {
+		case len(named) > 0:
+			arg = named[strings.ToUpper(query[p[0]+1:p[1]])]
+		case position < len(binds):
+			arg = binds[position]
+		}
+
+		position++
+
+		if c, ok := arg.(convertedBind); ok {
+			sb.WriteString(query[last:p[0]])
+			sb.WriteString(fmt.Sprintf(c.conversion, query[p[0]:p[1]]))
+			last = p[1]
+		}
+	}
+
+	sb.WriteString(query[last:])
+
+	args := make([]interface{}, len(binds))
+
+	for i, b := range binds {
+		switch v := b.(type) {
+		case convertedBind:
+			args[i] = v.text
+		case sql.NamedArg:
+			if c, ok := v.Value.(convertedBind); ok {
+				v.Value = c.text
+			}
+
+			args[i] = v
+		default:
+			args[i] = v
+		}
+	}
+
+	return sb.String(), args
+}
+
+// findPlaceholders returns the start and end offsets of the bind placeholders of a statement,
+// skipping string literals, quoted identifiers and comments.
+func findPlaceholders(query string) [][2]int {
+	var found [][2]int
+
+	isName := func(c byte) bool {
+		return c == '_' || c == '$' || c == '#' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+
+	// skipTo returns the offset after the end of the token closed by closing, or the end of the query.
+	skipTo := func(from int, closing string) int {
+		if n := strings.Index(query[from:], closing); n >= 0 {
+			return from + n + len(closing)
+		}
+
+		return len(query)
+	}
+
+	for i := 0; i < len(query); {
+		switch {
+		case strings.HasPrefix(query[i:], "--"):
+			i = skipTo(i+2, "\n")
+		case strings.HasPrefix(query[i:], "/*"):
+			i = skipTo(i+2, "*/")
+		case (query[i] == 'q' || query[i] == 'Q') && (i == 0 || !isName(query[i-1])) &&
+			i+2 < len(query) && query[i+1] == '\'':
+			// Alternative quoting: q'[...]', q'{...}', q'<...>', q'(...)' or q'X...X'.
+			closing := query[i+2]
+			if n := strings.IndexByte("[{<(", closing); n >= 0 {
+				closing = "]}>)"[n]
+			}
+
+			i = skipTo(i+3, string(closing)+"'")
+		case query[i] == '\'':
+			// A doubled quote inside a literal just ends one literal and starts the next one.
+			i = skipTo(i+1, "'")
+		case query[i] == '"':
+			i = skipTo(i+1, `"`)
+		case query[i] == ':' && i+1 < len(query) && isName(query[i+1]) && (i == 0 || !isName(query[i-1])):
+			end := i + 1
+			for end < len(query) && isName(query[end]) {
+				end++
+			}
+
+			found = append(found, [2]int{i, end})
+			i = end
+		default:
+			i++
+		}
+	}
+
+	return found
+}
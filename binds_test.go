@@ -0,0 +1,115 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/godror/godror"
+)
+
+func TestParseBinds(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []interface{}
+		wantErr bool
+	}{
+		{"plain strings", []string{"10/25/2020", "x"}, []interface{}{"10/25/2020", "x"}, false},
+		{"int", []string{"int:5", "int:-7"}, []interface{}{int64(5), int64(-7)}, false},
+		{"number", []string{"num:1.5", "num:12345678901234567890.123", "num:-2E3"},
+			[]interface{}{godror.Number("1.5"), godror.Number("12345678901234567890.123"), godror.Number("-2E3")}, false},
+		{"date", []string{"date:2020-10-25"},
+			[]interface{}{convertedBind{"2020-10-25", toDate}}, false},
+		{"timestamp", []string{"ts:2020-10-25T10:11:12", "ts:2020-10-25 10:11:12.5", "ts:2020-10-25T10:11:12Z"},
+			[]interface{}{
+				convertedBind{"2020-10-25 10:11:12.000000000", toTimestamp},
+				convertedBind{"2020-10-25 10:11:12.500000000", toTimestamp},
+				time.Date(2020, 10, 25, 10, 11, 12, 0, time.UTC),
+			}, false},
+		{"null", []string{"null"}, []interface{}{nil}, false},
+		{"escaped string", []string{"str:null", "str:int:5"}, []interface{}{"null", "int:5"}, false},
+		{"named", []string{":inst_id=int:1", ":name=prod"},
+			[]interface{}{sql.Named("inst_id", int64(1)), sql.Named("name", "prod")}, false},
+		{"not named", []string{":1=x", "a:b=c"}, []interface{}{":1=x", "a:b=c"}, false},
+		{"mixed", []string{":name=prod", "int:1"}, nil, true},
+		{"invalid int", []string{"int:five"}, nil, true},
+		{"invalid number", []string{"num:NaN"}, nil, true},
+		{"invalid date", []string{"date:10/25/2020"}, nil, true},
+		{"invalid timestamp", []string{"ts:yesterday"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBinds(tt.args, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBinds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBinds() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBindsUntyped(t *testing.T) {
+	args := []string{"null", "int:5", "ts:x", ":name=prod"}
+
+	got, err := parseBinds(args, false)
+	if err != nil {
+		t.Fatalf("parseBinds() error = %v", err)
+	}
+
+	if want := []interface{}{"null", "int:5", "ts:x", ":name=prod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseBinds() = %#v, want the arguments as strings %#v", got, want)
+	}
+}
+
+func TestConvertBinds(t *testing.T) {
+	date := convertedBind{"2020-10-25", toDate}
+
+	tests := []struct {
+		name      string
+		query     string
+		binds     []interface{}
+		wantQuery string
+		wantBinds []interface{}
+	}{
+		{
+			"nothing to convert",
+			"SELECT :1 FROM dual",
+			[]interface{}{"x"},
+			"SELECT :1 FROM dual",
+			[]interface{}{"x"},
+		},
+		{
+			"positional",
+			"SELECT ':1', \":2\" /* :3 */ FROM t WHERE a = :1 -- :4\nAND d = :2 AND q'[:x]' = :3",
+			[]interface{}{int64(1), date, nil},
+			"SELECT ':1', \":2\" /* :3 */ FROM t WHERE a = :1 -- :4\nAND d = TO_DATE(:2, 'YYYY-MM-DD') AND q'[:x]' = :3",
+			[]interface{}{int64(1), "2020-10-25", nil},
+		},
+		{
+			"named",
+			"BEGIN x := :Since; y := 'it''s :since'; z := :since + :days; END;",
+			[]interface{}{sql.Named("days", int64(1)), sql.Named("since", date)},
+			"BEGIN x := TO_DATE(:Since, 'YYYY-MM-DD'); y := 'it''s :since'; z := TO_DATE(:since, 'YYYY-MM-DD') + :days; END;",
+			[]interface{}{sql.Named("days", int64(1)), sql.Named("since", "2020-10-25")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotQuery, gotBinds := convertBinds(tt.query, tt.binds)
+			if gotQuery != tt.wantQuery {
+				t.Errorf("convertBinds() query = %q, want %q", gotQuery, tt.wantQuery)
+			}
+
+			if !reflect.DeepEqual(gotBinds, tt.wantBinds) {
+				t.Errorf("convertBinds() binds = %#v, want %#v", gotBinds, tt.wantBinds)
+			}
+		})
+	}
+}
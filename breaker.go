@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/uri"
+	"github.com/godror/godror"
+)
+
+// loginFailureCodes are the ORA errors of rejected logins, which count against FAILED_LOGIN_ATTEMPTS
+// or mean the account cannot log in until a DBA intervenes.
+var loginFailureCodes = map[int]bool{
+	1017:  true, // invalid username/password
+	28000: true, // account is locked
+	28001: true, // password has expired
+}
+
+// loginState is the login history of a URI.
+type loginState struct {
+	failures     int
+	passwordHash [sha256.Size]byte
+	suspended    time.Time
+	err          error
+}
+
+// loginBreaker suspends logins to a URI after threshold consecutive login failures,
+// so items polling with a wrong or expired password do not lock the account.
+// Until the backoff passes, the last login error is returned instead of connecting.
+// A changed password is always tried.
+type loginBreaker struct {
+	sync.Mutex
+	threshold int
+	backoff   time.Duration
+	states    map[uri.URI]*loginState
+}
+
+// newLoginBreaker initializes a loginBreaker. Zero threshold disables it.
+func newLoginBreaker(threshold int, backoff time.Duration) *loginBreaker {
+	return &loginBreaker{
+		threshold: threshold,
+		backoff:   backoff,
+		states:    make(map[uri.URI]*loginState),
+	}
+}
+
+// isLoginFailure reports whether err is a login rejected by the database.
+func isLoginFailure(err error) bool {
+	oraErr, ok := godror.AsOraErr(err)
+
+	return ok && loginFailureCodes[oraErr.Code()]
+}
+
+// check returns an error if logins to u with the password are suspended.
+func (b *loginBreaker) check(u uri.URI, passwordHash [sha256.Size]byte) error {
+	b.Lock()
+	defer b.Unlock()
+
+	s, ok := b.states[u]
+	if !ok || s.passwordHash != passwordHash || !time.Now().Before(s.suspended) {
+		return nil
+	}
+
+	return fmt.Errorf("%w; logins suspended until %s", s.err, s.suspended.Format(time.RFC3339))
+}
+
+// fail records a login failure. err is returned by check while logins are suspended.
+func (b *loginBreaker) fail(u uri.URI, passwordHash [sha256.Size]byte, err error) {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	s, ok := b.states[u]
+	if !ok || s.passwordHash != passwordHash {
+		s = &loginState{passwordHash: passwordHash}
+		b.states[u] = s
+	}
+
+	s.failures++
+	s.err = err
+
+	if s.failures >= b.threshold {
+		s.suspended = time.Now().Add(b.backoff)
+	}
+}
+
+// succeed forgets the failures of u.
+func (b *loginBreaker) succeed(u uri.URI) {
+	b.Lock()
+	defer b.Unlock()
+
+	delete(b.states, u)
+}
+
+// reset allows logins to u again. It reports whether they were suspended.
+func (b *loginBreaker) reset(u uri.URI) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	s, ok := b.states[u]
+	delete(b.states, u)
+
+	return ok && time.Now().Before(s.suspended)
+}
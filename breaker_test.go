@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/uri"
+)
+
+func TestLoginBreaker(t *testing.T) {
+	u := uri.URI{}
+	password := secretHash("secret")
+	loginErr := errors.New("Authentication failed: ORA-01017: invalid username/password; logon denied")
+
+	b := newLoginBreaker(2, time.Minute)
+
+	b.fail(u, password, loginErr)
+
+	if err := b.check(u, password); err != nil {
+		t.Fatalf("check() after 1 failure = %v, want nil", err)
+	}
+
+	b.fail(u, password, loginErr)
+
+	err := b.check(u, password)
+	if !errors.Is(err, loginErr) {
+		t.Fatalf("check() after 2 failures = %v, want %v", err, loginErr)
+	}
+
+	if err = b.check(u, secretHash("rotated")); err != nil {
+		t.Errorf("check() with a new password = %v, want nil", err)
+	}
+
+	if !b.reset(u) {
+		t.Errorf("reset() = false, want true")
+	}
+
+	if err = b.check(u, password); err != nil {
+		t.Errorf("check() after reset = %v, want nil", err)
+	}
+
+	if b.reset(u) {
+		t.Errorf("second reset() = true, want false")
+	}
+}
+
+func TestLoginBreakerSucceed(t *testing.T) {
+	u := uri.URI{}
+	password := secretHash("secret")
+
+	b := newLoginBreaker(2, time.Minute)
+
+	b.fail(u, password, errors.New("ORA-01017"))
+	b.succeed(u)
+	b.fail(u, password, errors.New("ORA-01017"))
+
+	if err := b.check(u, password); err != nil {
+		t.Errorf("check() = %v, want nil, failures must be consecutive", err)
+	}
+}
+
+func TestLoginBreakerDisabled(t *testing.T) {
+	u := uri.URI{}
+	password := secretHash("secret")
+
+	b := newLoginBreaker(0, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		b.fail(u, password, errors.New("ORA-01017"))
+	}
+
+	if err := b.check(u, password); err != nil {
+		t.Errorf("check() = %v, want nil", err)
+	}
+}
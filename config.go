@@ -2,8 +2,19 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
 	"git.zabbix.com/ap/plugin-support/conf"
 	"git.zabbix.com/ap/plugin-support/plugin"
+	"git.zabbix.com/ap/plugin-support/uri"
+	"git.zabbix.com/ap/plugin-support/zbxerr"
 )
  
 type Session struct {
@@ -16,6 +27,141 @@ type Session struct {
 
 	// Service name that identifies a database instance
 	Service string `conf:"optional"`
+
+	// Instance pins connections to a RAC instance. The Service key parameter can pin it as well: <service>/<instance>.
+	Instance string `conf:"optional"`
+
+	// ProxyUser is the account that authenticates with Password and connects on behalf of User.
+	// The same can be written as proxy[user] in User.
+	ProxyUser string `conf:"optional"`
+
+	// ExternalAuth makes the session authenticate through a Secure External Password Store wallet
+	// or the operating system instead of User and Password.
+	ExternalAuth int `conf:"optional,range=0:1,default=0"`
+
+	// ConnectString replaces the descriptor built from URI and Service, e.g. with a wallet credential alias.
+	ConnectString string `conf:"optional"`
+
+	// ConfigDir is the directory holding sqlnet.ora and tnsnames.ora (TNS_ADMIN) used to find the wallet.
+	// The Oracle client reads it once per process, so all sessions setting it must agree and it applies to all of them.
+	ConfigDir string `conf:"optional"`
+
+	// Pool makes the session use a godror session pool instead of standalone connections.
+	Pool int `conf:"optional,range=0:1,default=0"`
+
+	// PoolMinSessions is the number of sessions the pool keeps open.
+	PoolMinSessions int `conf:"optional,range=0:100,default=1"`
+
+	// PoolMaxSessions is the maximum number of sessions in the pool.
+	PoolMaxSessions int `conf:"optional,range=1:100,default=5"`
+
+	// PoolWaitTimeout is the maximum time in seconds for waiting for a free session of the pool.
+	PoolWaitTimeout int `conf:"optional,range=1:60,default=5"`
+
+	// ConnectionClass enables Database Resident Connection Pooling (DRCP) with the given class.
+	// It implies Pool.
+	ConnectionClass string `conf:"optional"`
+
+	// ConnectTimeout overrides the plugin-wide ConnectTimeout for the session.
+	ConnectTimeout int `conf:"optional,range=1:60"`
+
+	// CallTimeout overrides the plugin-wide CallTimeout for the session.
+	CallTimeout int `conf:"optional,range=1:600"`
+
+	// KeepAlive overrides the plugin-wide KeepAlive for the session.
+	KeepAlive int `conf:"optional,range=60:3600"`
+
+	// NLSDateFormat, NLSTimestampFormat, NLSNumericCharacters, NLSLanguage, NLSTerritory and TimeZone
+	// are set with ALTER SESSION on every new connection, so results do not depend on the agent environment.
+	NLSDateFormat        string `conf:"optional"`
+	NLSTimestampFormat   string `conf:"optional"`
+	NLSNumericCharacters string `conf:"optional"`
+	NLSLanguage          string `conf:"optional"`
+	NLSTerritory         string `conf:"optional"`
+	TimeZone             string `conf:"optional"`
+
+	// InitSQL is a statement executed on every new connection after the NLS settings,
+	// e.g. ALTER SESSION SET CURRENT_SCHEMA = app. Several statements are separated by lines containing only "/".
+	InitSQL string `conf:"optional"`
+
+	// InitSQLFile is a script executed after InitSQL, with statements separated by lines containing only "/".
+	InitSQLFile string `conf:"optional"`
+
+	// MaxOpenConns limits the number of sessions opened for the session at once.
+	// Zero means the plugin-wide MaxOpenConns is used.
+	MaxOpenConns int `conf:"optional,range=0:100,default=0"`
+}
+
+// initStatements returns the statements to execute on every new connection of the session.
+// InitSQLFile is left to the connection, it is read when the connection is created.
+func (s *Session) initStatements() []string {
+	var stmts []string
+
+	for _, nls := range [][2]string{
+		{"NLS_DATE_FORMAT", s.NLSDateFormat},
+		{"NLS_TIMESTAMP_FORMAT", s.NLSTimestampFormat},
+		{"NLS_NUMERIC_CHARACTERS", s.NLSNumericCharacters},
+		{"NLS_LANGUAGE", s.NLSLanguage},
+		{"NLS_TERRITORY", s.NLSTerritory},
+		{"TIME_ZONE", s.TimeZone},
+	} {
+		if nls[1] != "" {
+			stmts = append(stmts, fmt.Sprintf("ALTER SESSION SET %s = '%s'", nls[0], strings.ReplaceAll(nls[1], "'", "''")))
+		}
+	}
+
+	return append(stmts, splitScript(s.InitSQL)...)
+}
+
+// plsqlBlockRgx matches statements whose trailing ";" is a part of the statement.
+var plsqlBlockRgx = regexp.MustCompile(
+	`(?is)^(declare|begin|create\s+(or\s+replace\s+)?(function|procedure|package|trigger|type))\b`)
+
+// splitScript splits a script into statements on lines containing only "/", like SQL*Plus does,
+// so semicolons inside PL/SQL blocks and string literals are left alone.
+// The terminating ";" of a plain SQL statement is removed, since Oracle rejects it outside of SQL*Plus.
+func splitScript(script string) []string {
+	var (
+		stmts []string
+		lines []string
+	)
+
+	flush := func() {
+		stmt := strings.TrimSpace(strings.Join(lines, "\n"))
+		lines = nil
+
+		if !plsqlBlockRgx.MatchString(stmt) {
+			stmt = strings.TrimSpace(strings.TrimSuffix(stmt, ";"))
+		}
+
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(script, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "/" {
+			flush()
+
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	flush()
+
+	return stmts
+}
+
+// keySession holds the Session fields which can also be passed as key parameters.
+// metric.EvalParams maps every field of a session to the parameter with the same name,
+// so it must not see the session-only options.
+type keySession struct {
+	URI      string
+	Password string
+	User     string
+	Service  string
 }
 
 type PluginOptions struct {
@@ -33,11 +179,108 @@ type PluginOptions struct {
 	// KeepAlive is a time to wait before unused connections will be closed.
 	KeepAlive int `conf:"optional,range=60:900,default=300"`
 
+	// CustomQueriesPath is a directory with queries that can be referenced by file name without the .sql extension.
+	CustomQueriesPath string `conf:"optional"`
+
+	// Warmup makes Start connect to every configured session in the background.
+	Warmup int `conf:"optional,range=0:1,default=0"`
+
+	// FanOutConcurrency limits the number of sessions queried at once by a single zoracle.custom.query.multi item.
+	FanOutConcurrency int `conf:"optional,range=1:64,default=4"`
+
+	// MaxOpenConns limits the number of sessions opened to the same URI at once. Zero means no limit.
+	MaxOpenConns int `conf:"optional,range=0:100,default=0"`
+
+	// ErrorsAsValue makes custom query items return a failure as a JSON error object instead of becoming unsupported.
+	ErrorsAsValue int `conf:"optional,range=0:1,default=0"`
+
+	// LoginFailureThreshold is the number of consecutive failed logins to a URI after which
+	// new logins are suspended for LoginBackoff seconds, so the account does not get locked. Zero disables it.
+	LoginFailureThreshold int `conf:"optional,range=0:100,default=3"`
+
+	// LoginBackoff is a time in seconds logins stay suspended after LoginFailureThreshold failures.
+	LoginBackoff int `conf:"optional,range=10:86400,default=600"`
+
+	// SlowQueryThreshold is a time in milliseconds after which a custom query is logged as slow. Zero disables it.
+	SlowQueryThreshold int `conf:"optional,range=0:600000,default=0"`
+
+	// AuditLog is a JSON lines file recording every request which reached the database. Empty disables it.
+	AuditLog string `conf:"optional"`
+
+	// AuditLogMaxSize is the size in megabytes after which the audit log is rotated.
+	AuditLogMaxSize int `conf:"optional,range=1:1024,default=10"`
+
+	// AuditMaskBinds replaces bind values in the audit log with a mask.
+	AuditMaskBinds int `conf:"optional,range=0:1,default=1"`
+
+	// SecretCacheTTL is a time in seconds to keep passwords resolved from file:, env: or exec: sources.
+	SecretCacheTTL int `conf:"optional,range=0:86400,default=300"`
+
 	// Sessions stores pre-defined named sets of connections settings.
 	Sessions map[string]Session `conf:"optional"`
 
 }
 
+// keySessions returns the configured sessions reduced to the fields known by key parameters.
+func (o *PluginOptions) keySessions() map[string]keySession {
+	sessions := make(map[string]keySession, len(o.Sessions))
+	for name, s := range o.Sessions {
+		sessions[name] = keySession{URI: s.URI, Password: s.Password, User: s.User, Service: s.Service}
+	}
+
+	return sessions
+}
+
+// configDirs returns the distinct ConfigDir of all configured sessions in order.
+func (o *PluginOptions) configDirs() []string {
+	var dirs []string
+
+	seen := make(map[string]bool)
+
+	for _, s := range o.Sessions {
+		if s.ConfigDir != "" && !seen[s.ConfigDir] {
+			seen[s.ConfigDir] = true
+			dirs = append(dirs, s.ConfigDir)
+		}
+	}
+
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+// configDir returns the ConfigDir every connection is opened with, validate makes sure there is at most one.
+func (o *PluginOptions) configDir() string {
+	if dirs := o.configDirs(); len(dirs) > 0 {
+		return dirs[0]
+	}
+
+	return ""
+}
+
+// sessionPasswords returns passwords of all configured sessions.
+func (o *PluginOptions) sessionPasswords() []string {
+	passwords := make([]string, 0, len(o.Sessions))
+	for _, s := range o.Sessions {
+		passwords = append(passwords, s.Password)
+	}
+
+	return passwords
+}
+
+// getSession returns the session referenced by the first key parameter or nil if it is not a session name.
+func (o *PluginOptions) getSession(rawParams []string) (string, *Session) {
+	if len(rawParams) == 0 {
+		return "", nil
+	}
+
+	if s, ok := o.Sessions[rawParams[0]]; ok {
+		return rawParams[0], &s
+	}
+
+	return "", nil
+}
+
 // Configure implements the Configurator interface.
 // Initializes configuration structures.
 func (p *Plugin) Configure(global *plugin.GlobalOptions, options interface{}) {
@@ -45,6 +288,8 @@ func (p *Plugin) Configure(global *plugin.GlobalOptions, options interface{}) {
 		p.Errf("cannot unmarshal configuration options: %s", err)
 	}
 
+	p.redactor.add(p.options.sessionPasswords()...)
+
 	if p.options.ConnectTimeout == 0 {
 		p.options.ConnectTimeout = global.Timeout
 	}
@@ -52,6 +297,14 @@ func (p *Plugin) Configure(global *plugin.GlobalOptions, options interface{}) {
 	if p.options.CallTimeout == 0 {
 		p.options.CallTimeout = global.Timeout
 	}
+
+	if p.options.FanOutConcurrency == 0 {
+		p.options.FanOutConcurrency = defaultFanOutConcurrency
+	}
+
+	if p.options.AuditLogMaxSize == 0 {
+		p.options.AuditLogMaxSize = defaultAuditLogMaxSize
+	}
 }
 
 // Validate implements the Configurator interface.
@@ -59,5 +312,127 @@ func (p *Plugin) Configure(global *plugin.GlobalOptions, options interface{}) {
 func (p *Plugin) Validate(options interface{}) error {
 	var opts PluginOptions
 
-	return conf.Unmarshal(options, &opts)
+	if err := conf.Unmarshal(options, &opts); err != nil {
+		return err
+	}
+
+	return opts.validate()
+}
+
+// validate checks the options are consistent and the paths they reference exist.
+// All problems are reported at once, so they can be fixed before the agent is deployed.
+func (o *PluginOptions) validate() error {
+	var problems []string
+
+	if o.CustomQueriesPath != "" {
+		problems = append(problems, checkDir("CustomQueriesPath", o.CustomQueriesPath)...)
+	}
+
+	if o.AuditLog != "" {
+		problems = append(problems, checkDir("AuditLog", filepath.Dir(o.AuditLog))...)
+	}
+
+	names := make([]string, 0, len(o.Sessions))
+	for name := range o.Sessions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := o.Sessions[name]
+
+		for _, problem := range s.validate() {
+			problems = append(problems, fmt.Sprintf("session %q: %s", name, problem))
+		}
+	}
+
+	if dirs := o.configDirs(); len(dirs) > 1 {
+		problems = append(problems, fmt.Sprintf("sessions set different ConfigDir %q, the Oracle client uses one per process",
+			dirs))
+	}
+
+	if len(problems) > 0 {
+		return zbxerr.ErrorInvalidConfiguration.Wrap(errors.New(strings.Join(problems, "; ")))
+	}
+
+	return nil
+}
+
+// validate returns the problems of a session configuration.
+func (s *Session) validate() []string {
+	var problems []string
+
+	if s.URI != "" {
+		u := s.URI
+		if err := (uri.URIValidator{Defaults: uriDefaults, AllowedSchemes: []string{"tcp"}}).Validate(&u); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid Uri %q: %s", s.URI, err))
+		}
+	}
+
+	if s.ConnectString != "" && (s.URI != "" || s.Service != "" || s.Instance != "") {
+		problems = append(problems, "ConnectString cannot be combined with Uri, Service or Instance")
+	}
+
+	if _, instance, ok := strings.Cut(s.Service, "/"); ok && s.Instance != "" && !strings.EqualFold(instance, s.Instance) {
+		problems = append(problems, fmt.Sprintf("Service pins instance %q, but Instance is %q", instance, s.Instance))
+	}
+
+	if s.ExternalAuth == 1 {
+		if s.User != "" || s.Password != "" || s.ProxyUser != "" {
+			problems = append(problems, "ExternalAuth cannot be combined with User, Password or ProxyUser")
+		}
+	} else {
+		if s.Password != "" && s.User == "" {
+			problems = append(problems, "Password is set, but User is not")
+		}
+
+		if s.ProxyUser != "" && s.User == "" {
+			problems = append(problems, "ProxyUser is set, but User to connect on behalf of is not")
+		}
+	}
+
+	if s.ConfigDir != "" {
+		problems = append(problems, checkDir("ConfigDir", s.ConfigDir)...)
+	}
+
+	switch {
+	case strings.HasPrefix(s.Password, secretFilePrefix):
+		if _, err := os.Stat(strings.TrimPrefix(s.Password, secretFilePrefix)); err != nil {
+			problems = append(problems, fmt.Sprintf("password file: %s", err))
+		}
+	case strings.HasPrefix(s.Password, secretExecPrefix):
+		args := strings.Fields(strings.TrimPrefix(s.Password, secretExecPrefix))
+		if len(args) == 0 {
+			problems = append(problems, "password command is empty")
+		} else if _, err := exec.LookPath(args[0]); err != nil {
+			problems = append(problems, fmt.Sprintf("password command: %s", err))
+		}
+	}
+
+	if s.InitSQLFile != "" {
+		if _, err := os.Stat(s.InitSQLFile); err != nil {
+			problems = append(problems, fmt.Sprintf("InitSQLFile: %s", err))
+		}
+	}
+
+	if s.PoolMinSessions > s.PoolMaxSessions && s.PoolMaxSessions > 0 {
+		problems = append(problems, "PoolMinSessions is greater than PoolMaxSessions")
+	}
+
+	return problems
+}
+
+// checkDir returns a problem if path is not an existing directory.
+func checkDir(option, path string) []string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %s", option, err)}
+	}
+
+	if !info.IsDir() {
+		return []string{fmt.Sprintf("%s: %q is not a directory", option, path)}
+	}
+
+	return nil
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPluginOptions_validate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "ora.pw")
+
+	if err := os.WriteFile(file, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		options      PluginOptions
+		wantProblems []string
+	}{
+		{
+			name: "Valid configuration",
+			options: PluginOptions{CustomQueriesPath: dir, Sessions: map[string]Session{
+				"prod":   {URI: "tcp://db:1521", User: "zabbix", Password: "file:" + file, Service: "ORCL"},
+				"wallet": {ExternalAuth: 1, ConnectString: "prod_monitor", ConfigDir: dir},
+			}},
+		},
+		{
+			name:         "Missing queries directory",
+			options:      PluginOptions{CustomQueriesPath: filepath.Join(dir, "missing")},
+			wantProblems: []string{"CustomQueriesPath"},
+		},
+		{
+			name: "All session problems are reported",
+			options: PluginOptions{Sessions: map[string]Session{
+				"a": {Password: "secret"},
+				"b": {ConnectString: "alias", Service: "ORCL"},
+				"c": {ExternalAuth: 1, User: "zabbix"},
+				"d": {User: "zabbix", Password: "file:" + filepath.Join(dir, "missing"), ConfigDir: file},
+				"e": {Service: "ORCL/ORCL1", Instance: "ORCL2", ProxyUser: "monitor"},
+				"f": {ExternalAuth: 1, ConfigDir: dir},
+				"g": {ExternalAuth: 1, ConfigDir: os.TempDir()},
+			}},
+			wantProblems: []string{
+				`session "a": Password is set, but User is not`,
+				`session "b": ConnectString cannot be combined`,
+				`session "c": ExternalAuth cannot be combined`,
+				`session "d": password file`,
+				`session "d": ConfigDir`,
+				`session "e": Service pins instance`,
+				`session "e": ProxyUser is set`,
+				`sessions set different ConfigDir`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.validate()
+			if len(tt.wantProblems) == 0 {
+				if err != nil {
+					t.Errorf("PluginOptions.validate() error = %v, want nil", err)
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatal("PluginOptions.validate() error = nil, want problems")
+			}
+
+			for _, problem := range tt.wantProblems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("PluginOptions.validate() error = %v, want it to contain %q", err, problem)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitScript(t *testing.T) {
+	script := "ALTER SESSION SET CONTAINER = PDB1;\r\n/\n" +
+		"BEGIN\n  dbms_application_info.set_module('zabbix; agent', NULL);\nEND;\n  /  \n" +
+		"ALTER SESSION SET CURRENT_SCHEMA = APP\n\n/\n"
+
+	want := []string{
+		"ALTER SESSION SET CONTAINER = PDB1",
+		"BEGIN\n  dbms_application_info.set_module('zabbix; agent', NULL);\nEND;",
+		"ALTER SESSION SET CURRENT_SCHEMA = APP",
+	}
+
+	got := splitScript(script)
+	if len(got) != len(want) {
+		t.Fatalf("splitScript() = %q, want %q", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("splitScript()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if got := splitScript("SELECT 'a;b' FROM dual"); len(got) != 1 || got[0] != "SELECT 'a;b' FROM dual" {
+		t.Errorf("splitScript() = %q, want the statement intact", got)
+	}
+}
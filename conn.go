@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,18 +25,180 @@ type OraClient interface {
 	Query(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error)
 	QueryRow(ctx context.Context, query string, args ...interface{}) (row *sql.Row, err error)
 	WhoAmI() string
+	ServerVersion() string
+	InContainer(ctx context.Context, container string, f func(client OraClient) error) error
 }
 
 type OraConn struct {
 	client         *sql.DB
 	callTimeout    time.Duration
+	keepAlive      time.Duration
 	version        godror.VersionInfo
 	lastTimeAccess time.Time
 	ctx            context.Context
 	username       string
+	proxyUser      string
+	instance       string
+	passwordHash   [sha256.Size]byte
 }
 
-var errorQueryNotFound = "query %q not found"
+var (
+	errorQueryNotFound    = "query %q not found"
+	errorInstanceMismatch = "connected to instance %q instead of %q"
+	errorInitStatement    = "init statement %q failed: %w"
+	errorManagerStopped   = "plugin is stopping"
+)
+
+// connOptions holds the connection settings which are not a part of the connection identity.
+type connOptions struct {
+	password string
+
+	// sessionPassword tells password comes from a configured session, so it may reference a secret.
+	// Passwords given in item keys are always used literally.
+	sessionPassword bool
+
+	pool            bool
+	poolMinSessions int
+	poolMaxSessions int
+	poolWaitTimeout time.Duration
+	connClass       string
+	maxOpenConns    int
+	connectTimeout  time.Duration
+	callTimeout     time.Duration
+	keepAlive       time.Duration
+	initStmts       []string
+	initSQLFile     string
+}
+
+// newConnParams builds the URI identifying a connection and the options needed to open it.
+// The password is deliberately kept out of the URI, so the identity does not change when
+// the password does, or when there is no password at all because of external authentication.
+func newConnParams(options *PluginOptions, params map[string]string, sessionName string,
+	session *Session) (*uri.URI, connOptions, error) {
+	user := params["User"]
+	opts := connOptions{password: params["Password"], maxOpenConns: options.MaxOpenConns}
+
+	// The service can be followed by an instance name like in Easy Connect: <service>/<instance>.
+	service, instance, _ := strings.Cut(params["Service"], "/")
+
+	query := url.Values{}
+	query.Set("service", service)
+
+	if session != nil {
+		query.Set("session", sessionName)
+
+		opts.sessionPassword = session.Password != "" && opts.password == session.Password
+
+		if instance == "" {
+			instance = session.Instance
+		}
+
+		if session.ProxyUser != "" && user != "" && !strings.Contains(user, "[") {
+			user = session.ProxyUser + "[" + user + "]"
+		}
+
+		if session.ExternalAuth == 1 {
+			query.Set("auth", "external")
+			user = ""
+			opts.password = ""
+		}
+
+		if session.ConnectString != "" {
+			query.Set("connect", session.ConnectString)
+		}
+
+
+		if session.MaxOpenConns > 0 {
+			opts.maxOpenConns = session.MaxOpenConns
+		}
+
+		opts.initStmts = session.initStatements()
+		opts.initSQLFile = session.InitSQLFile
+
+		opts.connectTimeout = time.Duration(session.ConnectTimeout) * time.Second
+		opts.callTimeout = time.Duration(session.CallTimeout) * time.Second
+		opts.keepAlive = time.Duration(session.KeepAlive) * time.Second
+
+		if session.Pool == 1 || session.ConnectionClass != "" {
+			opts.pool = true
+			opts.connClass = session.ConnectionClass
+			opts.poolMinSessions = session.PoolMinSessions
+			opts.poolMaxSessions = session.PoolMaxSessions
+			opts.poolWaitTimeout = time.Duration(session.PoolWaitTimeout) * time.Second
+
+			if opts.poolMaxSessions == 0 {
+				opts.poolMaxSessions = defaultPoolMaxSessions
+			}
+
+			if opts.poolWaitTimeout == 0 {
+				opts.poolWaitTimeout = defaultPoolWaitTimeout
+			}
+
+			// More open connections than pooled sessions would only wait for the pool.
+			if opts.maxOpenConns == 0 || opts.maxOpenConns > opts.poolMaxSessions {
+				opts.maxOpenConns = opts.poolMaxSessions
+			}
+		}
+	}
+
+	if instance != "" {
+		query.Set("instance", instance)
+	}
+
+	// The first connection initializes the Oracle client for the whole process, whichever session it belongs to.
+	if configDir := options.configDir(); configDir != "" {
+		query.Set("configdir", configDir)
+	}
+
+	u, err := uri.NewWithCreds(params["URI"]+"?"+query.Encode(), user, "", uriDefaults)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	return u, opts, nil
+}
+
+// connectDescriptor builds an Oracle Net connect descriptor for a listener address.
+// A pooled server is requested for Database Resident Connection Pooling.
+func connectDescriptor(host, port, service, instance string, pooledServer bool, connectTimeout time.Duration) string {
+	connectData := fmt.Sprintf(`(SERVICE_NAME="%s")`, service)
+	if instance != "" {
+		connectData += fmt.Sprintf(`(INSTANCE_NAME="%s")`, instance)
+	}
+
+	if pooledServer {
+		connectData += "(SERVER=POOLED)"
+	}
+
+	return fmt.Sprintf(`(DESCRIPTION=(ADDRESS=(PROTOCOL=tcp)(HOST=%s)(PORT=%s))`+
+		`(CONNECT_DATA=%s)(CONNECT_TIMEOUT=%d)(RETRY_COUNT=0))`,
+		host, port, connectData, connectTimeout/time.Second)
+}
+
+// execInitStatements returns a godror OnInit function executing stmts on every new session.
+// A failure names the statement, so a broken InitSQL is easy to tell from a broken connection.
+func execInitStatements(stmts []string) func(context.Context, driver.ConnPrepareContext) error {
+	return func(ctx context.Context, conn driver.ConnPrepareContext) error {
+		for _, stmt := range stmts {
+			st, err := conn.PrepareContext(ctx, stmt)
+			if err == nil {
+				_, err = st.(driver.StmtExecContext).ExecContext(ctx, nil)
+				st.Close()
+			}
+
+			if err != nil {
+				return fmt.Errorf(errorInitStatement, stmt, err)
+			}
+		}
+
+		return nil
+	}
+}
+
+// getURIParam returns an unescaped query parameter of a connection URI.
+func getURIParam(u uri.URI, name string) (string, error) {
+	return url.QueryUnescape(u.GetParam(name))
+}
 
 // Query wraps DB.QueryContext.
 func (conn *OraConn) Query(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
@@ -55,11 +222,21 @@ func (conn *OraConn) QueryRow(ctx context.Context, query string, args ...interfa
 	return
 }
 
-// WhoAmI returns a current username.
+// WhoAmI returns a current username. A proxied session is reported as proxy[user],
+// where user is the schema the session acts on behalf of.
 func (conn *OraConn) WhoAmI() string {
+	if conn.proxyUser != "" {
+		return conn.proxyUser + "[" + conn.username + "]"
+	}
+
 	return conn.username
 }
 
+// ServerVersion returns the version of the database server.
+func (conn *OraConn) ServerVersion() string {
+	return conn.version.String()
+}
+
 // updateAccessTime updates the last time a connection was accessed.
 func (conn *OraConn) updateAccessTime() {
 	conn.lastTimeAccess = time.Now()
@@ -67,27 +244,46 @@ func (conn *OraConn) updateAccessTime() {
 
 // ConnManager is thread-safe structure for manage connections.
 type ConnManager struct {
-	sync.Mutex
+	// uriMutex guards uriLocks, which serialize creating connections of the same URI.
+	uriMutex       sync.Mutex
+	uriLocks       map[uri.URI]*uriLock
 	connMutex      sync.Mutex
 	connections    map[uri.URI]*OraConn
 	keepAlive      time.Duration
 	connectTimeout time.Duration
 	callTimeout    time.Duration
-	Destroy        context.CancelFunc
 	queryStorage   yarn.Yarn
+	secrets        *secretCache
+	logins         *loginBreaker
+	stats          *pluginStats
+
+	// ctx lives as long as the manager, every query context is derived from it.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// inFlight counts requests using connections, stopMutex orders adding to it with the cancellation.
+	stopMutex sync.Mutex
+	inFlight  sync.WaitGroup
 }
 
 // NewConnManager initializes connManager structure and runs Go Routine that watches for unused connections.
+// Logins to a URI are suspended for loginBackoff after loginThreshold consecutive login failures.
 func NewConnManager(keepAlive, connectTimeout, callTimeout,
-	hkInterval time.Duration) *ConnManager {
+	hkInterval, secretTTL, loginBackoff time.Duration, loginThreshold int, queryStorage yarn.Yarn) *ConnManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	connMgr := &ConnManager{
 		connections:    make(map[uri.URI]*OraConn),
+		uriLocks:       make(map[uri.URI]*uriLock),
 		keepAlive:      keepAlive,
 		connectTimeout: connectTimeout,
 		callTimeout:    callTimeout,
-		Destroy:        cancel, // Destroy stops originated goroutines and closes connections.
+		secrets:        newSecretCache(secretTTL, connectTimeout),
+		logins:         newLoginBreaker(loginThreshold, loginBackoff),
+		stats:          newPluginStats(),
+		queryStorage:   queryStorage,
+		ctx:            ctx,
+		cancel:         cancel,
 	}
 
 	go connMgr.housekeeper(ctx, hkInterval)
@@ -95,20 +291,86 @@ func NewConnManager(keepAlive, connectTimeout, callTimeout,
 	return connMgr
 }
 
+// Destroy stops originated goroutines, breaks in-flight queries, waits up to timeout
+// for their requests to return and closes all connections.
+func (c *ConnManager) Destroy(timeout time.Duration) {
+	c.stopMutex.Lock()
+	c.cancel()
+	c.stopMutex.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		c.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Warningf("[%s] Closing connections with requests still in progress", pluginName)
+	}
+
+	c.closeAll()
+}
+
+// begin registers a request using connections. It fails once the manager is being destroyed.
+func (c *ConnManager) begin() error {
+	c.stopMutex.Lock()
+	defer c.stopMutex.Unlock()
+
+	if c.ctx.Err() != nil {
+		return errors.New(errorManagerStopped)
+	}
+
+	c.inFlight.Add(1)
+	c.stats.begin()
+
+	return nil
+}
+
+// end unregisters a request registered with begin.
+func (c *ConnManager) end() {
+	c.stats.end()
+	c.inFlight.Done()
+}
+
+// statsSummary reports the statistics of the manager.
+func (c *ConnManager) statsSummary() statsSummary {
+	c.connMutex.Lock()
+	connections := len(c.connections)
+	c.connMutex.Unlock()
+
+	return c.stats.summary(connections)
+}
+
 // closeUnused closes each connection that has not been accessed at least within the keepalive interval.
 func (c *ConnManager) closeUnused() {
 	c.connMutex.Lock()
 	defer c.connMutex.Unlock()
 
 	for uri, conn := range c.connections {
-		if time.Since(conn.lastTimeAccess) > c.keepAlive {
+		if time.Since(conn.lastTimeAccess) > conn.keepAlive {
 			conn.client.Close()
 			delete(c.connections, uri)
+			c.stats.connEvicted(uri)
 			log.Debugf("[%s] Closed unused connection: %s", pluginName, uri.Addr())
 		}
 	}
 }
 
+// closeConn closes a connection with given uri if it exists.
+func (c *ConnManager) closeConn(uri uri.URI) {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+
+	if conn, ok := c.connections[uri]; ok {
+		conn.client.Close()
+		delete(c.connections, uri)
+		log.Debugf("[%s] Closed connection: %s", pluginName, uri.Addr())
+	}
+}
+
 // closeAll closes all existed connections.
 func (c *ConnManager) closeAll() {
 	c.connMutex.Lock()
@@ -127,7 +389,6 @@ func (c *ConnManager) housekeeper(ctx context.Context, interval time.Duration) {
 		select {
 		case <-ctx.Done():
 			ticker.Stop()
-			c.closeAll()
 
 			return
 		case <-ticker.C:
@@ -137,74 +398,241 @@ func (c *ConnManager) housekeeper(ctx context.Context, interval time.Duration) {
 }
 
 // create creates a new connection with given credentials.
-func (c *ConnManager) create(p *Plugin, uri uri.URI) (*OraConn, error) {
+func (c *ConnManager) create(p *Plugin, uri uri.URI, opts connOptions) (*OraConn, error) {
 	p.Tracef("[Connection create] begin")
 
+	// Only connMutex is released while connecting, the caller holds the lock of the URI,
+	// so no other connection of it can be added meanwhile.
 	c.connMutex.Lock()
-	defer c.connMutex.Unlock()
+	_, ok := c.connections[uri]
+	c.connMutex.Unlock()
 
-	if _, ok := c.connections[uri]; ok {
+	if ok {
 		// Should never happen.
 		panic("connection already exists")
 	}
 
+	conn, err := c.connect(p, uri, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.connMutex.Lock()
+	c.connections[uri] = conn
+	c.connMutex.Unlock()
+
+	c.stats.connCreated(uri)
+
+	p.Tracef("[Connection create] created new connection")
+	p.Tracef("[Connection create] %v", uri.Addr())
+	log.Debugf("[%s] Created new connection: %s", pluginName, uri.Addr())
+
+	return conn, nil
+}
+
+// connect opens a connection with given credentials without adding it to the manager.
+func (c *ConnManager) connect(p *Plugin, uri uri.URI, opts connOptions) (*OraConn, error) {
 	p.Tracef("[Connection create] trace 1")
 	ctx := godror.ContextWithTraceTag(
-		context.Background(),
+		c.ctx,
 		godror.TraceTag{
-			ClientInfo: "zbx_monitor",
+			ClientInfo: traceClientInfo,
 			Module:     godror.DriverName,
 		})
 
 	p.Tracef("[Connection create] trace 2")
-	service, err := url.QueryUnescape(uri.GetParam("service"))
+	connParams, opts, err := c.connectionParams(uri, opts)
 	p.Tracef("[Connection create] trace 3")
 	if err != nil {
 		return nil, err
 	}
 
-	connectString := fmt.Sprintf(`(DESCRIPTION=(ADDRESS=(PROTOCOL=tcp)(HOST=%s)(PORT=%s))`+
-		`(CONNECT_DATA=(SERVICE_NAME="%s"))(CONNECT_TIMEOUT=%d)(RETRY_COUNT=0))`,
-		uri.Host(), uri.Port(), service, c.connectTimeout/time.Second)
+	instance, err := getURIParam(uri, "instance")
+	if err != nil {
+		return nil, err
+	}
 
-	p.Tracef("[Connection create] %s", connectString)
+	p.Tracef("[Connection create] %s", connParams.ConnectString)
 
 	p.Tracef("[Connection create] trace 4")
-	connector := godror.NewConnector(godror.ConnectionParams{
-		StandaloneConnection: true,
-		CommonParams: godror.CommonParams{
-			Username:      uri.User(),
-			ConnectString: connectString,
-			Password:      godror.NewPassword(uri.Password()),
-		},
-	})
+	initStmts := opts.initStmts
+
+	if opts.initSQLFile != "" {
+		script, err := os.ReadFile(opts.initSQLFile)
+		if err != nil {
+			return nil, zbxerr.ErrorInvalidConfiguration.Wrap(err)
+		}
+
+		initStmts = append(initStmts[:len(initStmts):len(initStmts)], splitScript(string(script))...)
+	}
+
+	if len(initStmts) > 0 {
+		connParams.OnInit = execInitStatements(initStmts)
+	}
+
+	connector := godror.NewConnector(connParams)
+
+	// Closing a connector of the default driver leaves its session pools open, a pool of a driver of its own
+	// is purged when the connection is closed, e.g. after the password changed or the plugin stopped.
+	if opts.pool {
+		connector = godror.NewDriver().NewConnector(connParams)
+	}
 
 	p.Tracef("[Connection create] trace 5")
 	client := sql.OpenDB(connector)
+	client.SetMaxOpenConns(opts.maxOpenConns)
 
 	p.Tracef("[Connection create] trace 6")
 	serverVersion, err := godror.ServerVersion(ctx, client)
 	p.Tracef("[Connection create] trace 7")
 	if err != nil {
 		p.Tracef("[Connection create] trace 8 error returning...")
+		client.Close()
 		return nil, err
 	}
 	p.Tracef("[Connection create] trace 9")
 
-	c.connections[uri] = &OraConn{
+	// The session user is asked from the database because it is not known for external authentication,
+	// and because a proxy connection acts on behalf of another user.
+	var sessionUser, proxyUser, instanceName sql.NullString
+
+	err = client.QueryRowContext(ctx, `SELECT sys_context('USERENV', 'SESSION_USER'), `+
+		`sys_context('USERENV', 'PROXY_USER'), sys_context('USERENV', 'INSTANCE_NAME') FROM DUAL`).
+		Scan(&sessionUser, &proxyUser, &instanceName)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	// A listener may hand the connection over to another node, e.g. when a custom connect string is used,
+	// so the instance is verified rather than reporting data of another node.
+	if instance != "" && !strings.EqualFold(instance, instanceName.String) {
+		client.Close()
+		return nil, fmt.Errorf(errorInstanceMismatch, instanceName.String, instance)
+	}
+
+	return &OraConn{
 		client:         client,
-		callTimeout:    c.callTimeout,
+		callTimeout:    opts.callTimeout,
+		keepAlive:      opts.keepAlive,
 		version:        serverVersion,
 		lastTimeAccess: time.Now(),
 		ctx:            ctx,
-		username:       uri.User(),
+		username:       sessionUser.String,
+		proxyUser:      proxyUser.String,
+		instance:       instanceName.String,
+		passwordHash:   secretHash(opts.password),
+	}, nil
+}
+
+// connectionParams returns the godror parameters of a connection and opts completed with the defaults of the manager.
+// Init statements are left to the caller.
+func (c *ConnManager) connectionParams(uri uri.URI, opts connOptions) (godror.ConnectionParams, connOptions, error) {
+	var connParams godror.ConnectionParams
+
+	service, err := getURIParam(uri, "service")
+	if err != nil {
+		return connParams, opts, err
 	}
 
-	p.Tracef("[Connection create] created new connection")
-	p.Tracef("[Connection create] %v", uri.Addr())
-	log.Debugf("[%s] Created new connection: %s", pluginName, uri.Addr())
+	instance, err := getURIParam(uri, "instance")
+	if err != nil {
+		return connParams, opts, err
+	}
+
+	connectString, err := getURIParam(uri, "connect")
+	if err != nil {
+		return connParams, opts, err
+	}
+
+	if opts.connectTimeout == 0 {
+		opts.connectTimeout = c.connectTimeout
+	}
+
+	if opts.callTimeout == 0 {
+		opts.callTimeout = c.callTimeout
+	}
+
+	if opts.keepAlive == 0 {
+		opts.keepAlive = c.keepAlive
+	}
+
+	if connectString == "" {
+		connectString = connectDescriptor(uri.Host(), uri.Port(), service, instance, opts.connClass != "", opts.connectTimeout)
+	}
+
+	configDir, err := getURIParam(uri, "configdir")
+	if err != nil {
+		return connParams, opts, err
+	}
+
+	// An empty username and password make godror authenticate externally,
+	// either with a wallet credential matching the connect string or as the OS user.
+	username, password := uri.User(), opts.password
+	externalAuth := uri.GetParam("auth") == "external"
+
+	if externalAuth {
+		username, password = "", ""
+	}
 
-	return c.connections[uri], nil
+	connParams = godror.ConnectionParams{
+		StandaloneConnection: !opts.pool,
+		CommonParams: godror.CommonParams{
+			Username:      username,
+			ConnectString: connectString,
+			Password:      godror.NewPassword(password),
+			ConfigDir:     configDir,
+		},
+	}
+
+	if opts.pool {
+		connParams.ConnClass = opts.connClass
+		connParams.PoolParams = godror.PoolParams{
+			MinSessions:      opts.poolMinSessions,
+			MaxSessions:      opts.poolMaxSessions,
+			SessionIncrement: 1,
+			WaitTimeout:      opts.poolWaitTimeout,
+			MaxLifeTime:      godror.DefaultMaxLifeTime,
+			SessionTimeout:   opts.keepAlive,
+			// godror derives it only when parsing a connection string, which ConnectionParams skip.
+			ExternalAuth: externalAuth,
+		}
+	}
+
+	return connParams, opts, nil
+}
+
+// uriLock serializes creating connections of one URI, refs counts the requests holding or waiting for it.
+type uriLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockURI locks creating connections of the uri and returns the function unlocking it.
+// Requests to the same URI wait for each other, so a login happens once, while other URIs
+// are not held up by a slow or unreachable database.
+func (c *ConnManager) lockURI(uri uri.URI) (unlock func()) {
+	c.uriMutex.Lock()
+	l, ok := c.uriLocks[uri]
+	if !ok {
+		l = &uriLock{}
+		c.uriLocks[uri] = l
+	}
+	l.refs++
+	c.uriMutex.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		c.uriMutex.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(c.uriLocks, uri)
+		}
+		c.uriMutex.Unlock()
+	}
 }
 
 // get returns a connection with given uri if it exists and also updates lastTimeAccess, otherwise returns nil.
@@ -220,37 +648,91 @@ func (c *ConnManager) get(uri uri.URI) *OraConn {
 	return nil
 }
 
+// resolvePassword returns the password to log in with. Only a password of a configured session
+// is resolved from file:, env: or exec:, otherwise anyone editing an item key could run commands as the agent.
+func (c *ConnManager) resolvePassword(opts connOptions) (string, error) {
+	if !opts.sessionPassword {
+		return opts.password, nil
+	}
+
+	return c.secrets.resolve(opts.password)
+}
+
 // GetConnection returns an existing connection or creates a new one.
-func (c *ConnManager) GetConnection(p *Plugin, uri uri.URI) (conn *OraConn, err error) {
+func (c *ConnManager) GetConnection(p *Plugin, uri uri.URI, opts connOptions) (conn *OraConn, err error) {
 	p.Tracef("[GetConnection] begining")
-	c.Lock()
+	unlock := c.lockURI(uri)
 	p.Tracef("[GetConnection] connection locked")
 	
-	defer c.Unlock()
+	defer unlock()
+
+	opts.password, err = c.resolvePassword(opts)
+	if err != nil {
+		return nil, zbxerr.ErrorConnectionFailed.Wrap(err)
+	}
+
+	p.redactor.add(opts.password)
 
 	p.Tracef("[GetConnection] check connection already exists")
 	conn = c.get(uri)
 
+	if conn != nil && conn.passwordHash != secretHash(opts.password) {
+		p.Tracef("[GetConnection] Password has changed. Recreating connection ...")
+		c.closeConn(uri)
+		conn = nil
+	}
+
+	c.stats.cacheLookup(conn != nil)
+
 	if conn == nil {
+		if err = c.logins.check(uri, secretHash(opts.password)); err != nil {
+			p.Tracef("[GetConnection] logins are suspended")
+
+			return nil, err
+		}
+
 		p.Tracef("[GetConnection] Connection doesn't exists. Creating ...")
-		conn, err = c.create(p, uri)
+		conn, err = c.create(p, uri, opts)
+		if err == nil {
+			c.logins.succeed(uri)
+		}
 	}
 
 	if err != nil {
 		p.Tracef("[GetConnection] error creating connection")
 		p.Tracef("[GetConnection] %s", err.Error())
-	
-		if oraErr, isOraErr := godror.AsOraErr(err); isOraErr {
-			p.Tracef("[GetConnection] error trace 1")
-			err = zbxerr.ErrorConnectionFailed.Wrap(oraErr)
-			p.Tracef("[GetConnection] error trace 1")
-		} else {
-			p.Tracef("[GetConnection] error trace 2")
-			err = zbxerr.ErrorConnectionFailed.Wrap(err)
-			p.Tracef("[GetConnection] error trace 2")
-		}
+
+		err = c.connectError(uri, secretHash(opts.password), err)
 	}
 	p.Tracef("[GetConnection] returning")
 
 	return
 }
+
+// connectError classifies an error of opening a connection and records a rejected login with the breaker.
+func (c *ConnManager) connectError(uri uri.URI, passwordHash [sha256.Size]byte, err error) error {
+	loginFailed := isLoginFailure(err)
+	err = classifyError(err, zbxerr.ErrorConnectionFailed)
+
+	if loginFailed {
+		c.logins.fail(uri, passwordHash, err)
+	}
+
+	return err
+}
+
+// sessionError classifies an error of opening a new session of a cached connection.
+// database/sql logs in for every new session, so a rejected login counts against the breaker too,
+// and the connection is dropped, so the next request is checked by the breaker before logging in again.
+func (c *ConnManager) sessionError(uri uri.URI, conn *OraConn, err error) error {
+	if isLoginFailure(err) {
+		c.connMutex.Lock()
+		if c.connections[uri] == conn {
+			conn.client.Close()
+			delete(c.connections, uri)
+		}
+		c.connMutex.Unlock()
+	}
+
+	return c.connectError(uri, conn.passwordHash, err)
+}
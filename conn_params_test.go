@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/uri"
+)
+
+func TestNewConnParams(t *testing.T) {
+	options := &PluginOptions{}
+	params := map[string]string{"URI": "tcp://db:1521", "User": "zabbix", "Password": "s3cr3t", "Service": "ORCL"}
+
+	u, opts, err := newConnParams(options, params, "", nil)
+	if err != nil {
+		t.Fatalf("newConnParams() error = %v", err)
+	}
+
+	if u.User() != "zabbix" || u.Password() != "" {
+		t.Errorf("newConnParams() URI user %q, password %q, want zabbix and no password", u.User(), u.Password())
+	}
+
+	if service, _ := getURIParam(*u, "service"); service != "ORCL" {
+		t.Errorf("newConnParams() service = %q, want ORCL", service)
+	}
+
+	if opts.password != "s3cr3t" {
+		t.Errorf("newConnParams() password = %q, want s3cr3t", opts.password)
+	}
+
+	// The password only authenticates the connection, another one must not make another connection.
+	params["Password"] = "other"
+
+	u2, _, err := newConnParams(options, params, "", nil)
+	if err != nil {
+		t.Fatalf("newConnParams() error = %v", err)
+	}
+
+	if *u != *u2 {
+		t.Errorf("newConnParams() identity depends on the password: %v != %v", *u, *u2)
+	}
+}
+
+func TestNewConnParamsExternalAuth(t *testing.T) {
+	session := &Session{URI: "tcp://db:1521", Service: "ORCL", ExternalAuth: 1}
+	options := &PluginOptions{Sessions: map[string]Session{"prod": *session}}
+	params := map[string]string{"URI": session.URI, "User": "", "Password": "", "Service": session.Service}
+
+	u, opts, err := newConnParams(options, params, "prod", session)
+	if err != nil {
+		t.Fatalf("newConnParams() error = %v", err)
+	}
+
+	if u.User() != "" || opts.password != "" {
+		t.Errorf("newConnParams() user %q, password %q, want both empty", u.User(), opts.password)
+	}
+
+	if auth := u.GetParam("auth"); auth != "external" {
+		t.Errorf("newConnParams() auth = %q, want external", auth)
+	}
+
+	opts.pool = true
+
+	connParams, _, err := (&ConnManager{}).connectionParams(*u, opts)
+	if err != nil {
+		t.Fatalf("connectionParams() error = %v", err)
+	}
+
+	if !connParams.ExternalAuth || connParams.Username != "" || !connParams.Password.IsZero() {
+		t.Errorf("connectionParams() of a pool = %+v, want external authentication without credentials",
+			connParams.PoolParams)
+	}
+
+	// Items of a password session must not share connections with external authentication.
+	params["User"], params["Password"] = "zabbix", "s3cr3t"
+
+	u2, _, err := newConnParams(options, params, "", nil)
+	if err != nil {
+		t.Fatalf("newConnParams() error = %v", err)
+	}
+
+	if *u == *u2 {
+		t.Errorf("newConnParams() external and password authentication share identity %v", *u)
+	}
+}
+
+func TestNewConnParamsConfigDir(t *testing.T) {
+	options := &PluginOptions{Sessions: map[string]Session{
+		"wallet": {ExternalAuth: 1, ConnectString: "prod_monitor", ConfigDir: "/etc/zabbix/oracle"},
+	}}
+
+	// A connection opened first for a password item initializes the Oracle client for the wallet session too.
+	u, _, err := newConnParams(options, map[string]string{"URI": "tcp://db:1521", "User": "zabbix"}, "", nil)
+	if err != nil {
+		t.Fatalf("newConnParams() error = %v", err)
+	}
+
+	if dir, _ := getURIParam(*u, "configdir"); dir != "/etc/zabbix/oracle" {
+		t.Errorf("newConnParams() configdir = %q, want /etc/zabbix/oracle", dir)
+	}
+}
+
+func TestNewConnParamsInitSQL(t *testing.T) {
+	session := &Session{URI: "tcp://db:1521", InitSQL: "ALTER SESSION SET CURRENT_SCHEMA = APP;",
+		InitSQLFile: filepath.Join(t.TempDir(), "missing.sql")}
+
+	// The file is read only when a connection is created, a cached connection keeps serving items.
+	_, opts, err := newConnParams(&PluginOptions{}, map[string]string{"URI": session.URI}, "prod", session)
+	if err != nil {
+		t.Fatalf("newConnParams() error = %v", err)
+	}
+
+	if len(opts.initStmts) != 1 || opts.initStmts[0] != "ALTER SESSION SET CURRENT_SCHEMA = APP" ||
+		opts.initSQLFile != session.InitSQLFile {
+		t.Errorf("newConnParams() init statements %q, file %q", opts.initStmts, opts.initSQLFile)
+	}
+}
+
+func TestConnManager_resolvePassword(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "executed")
+	ref := "exec:touch " + marker
+
+	c := &ConnManager{secrets: newSecretCache(time.Minute, time.Second)}
+
+	// A key parameter is bound literally, whatever it looks like.
+	_, opts, err := newConnParams(&PluginOptions{},
+		map[string]string{"URI": "tcp://db:1521", "User": "zabbix", "Password": ref}, "", nil)
+	if err != nil {
+		t.Fatalf("newConnParams() error = %v", err)
+	}
+
+	got, err := c.resolvePassword(opts)
+	if err != nil || got != ref {
+		t.Errorf("resolvePassword() = %q, %v, want %q", got, err, ref)
+	}
+
+	if _, err = os.Stat(marker); err == nil {
+		t.Fatalf("password command of a key parameter was executed")
+	}
+
+	// The same reference in a configured session is resolved.
+	session := &Session{URI: "tcp://db:1521", User: "zabbix", Password: "exec:echo from_exec"}
+
+	_, opts, err = newConnParams(&PluginOptions{},
+		map[string]string{"URI": session.URI, "User": session.User, "Password": session.Password}, "prod", session)
+	if err != nil {
+		t.Fatalf("newConnParams() error = %v", err)
+	}
+
+	if got, err = c.resolvePassword(opts); err != nil || got != "from_exec" {
+		t.Errorf("resolvePassword() of a session = %q, %v, want from_exec", got, err)
+	}
+}
+
+func TestConnManager_lockURI(t *testing.T) {
+	c := &ConnManager{uriLocks: make(map[uri.URI]*uriLock)}
+
+	db1, _ := uri.New("tcp://db1:1521", nil)
+	db2, _ := uri.New("tcp://db2:1521", nil)
+
+	unlock := c.lockURI(*db1)
+
+	// Another URI is not held up by a connection being created.
+	done := make(chan struct{})
+	go func() {
+		c.lockURI(*db2)()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("lockURI() of another URI waited for a held lock")
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		c.lockURI(*db1)()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatalf("lockURI() of the same URI did not wait for a held lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	<-locked
+
+	if len(c.uriLocks) != 0 {
+		t.Errorf("lockURI() left %d locks after unlocking", len(c.uriLocks))
+	}
+}
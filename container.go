@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+var errorUnknownContainer = "pluggable database %q not found"
+
+// InContainer takes a session of the connection, switches it to a pluggable database and runs f there.
+// The session is switched back afterwards, so it can be reused for the root container.
+func (conn *OraConn) InContainer(ctx context.Context, container string, f func(client OraClient) error) error {
+	sc, err := conn.client.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	client := &sessionClient{conn: sc, owner: conn}
+
+	return inContainer(ctx, sc, container, func() error { return f(client) })
+}
+
+// inContainer switches the session to container for the time f runs.
+// The name is looked up in gv$pdbs and only the name returned by the database is used in the statement,
+// so the parameter cannot inject SQL.
+func inContainer(ctx context.Context, sc *sql.Conn, container string, f func() error) error {
+	var current, name string
+
+	err := sc.QueryRowContext(ctx, `SELECT sys_context('USERENV', 'CON_NAME') FROM DUAL`).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	err = sc.QueryRowContext(ctx, `SELECT name FROM gv$pdbs WHERE name = upper(:1) AND rownum = 1`, container).
+		Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf(errorUnknownContainer, container)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = sc.ExecContext(ctx, `ALTER SESSION SET CONTAINER = "`+name+`"`); err != nil {
+		return err
+	}
+
+	defer func() {
+		// ctx may be already expired here, but the session must not stay in the pluggable database.
+		// A session which does not switch back in time is not worth waiting for.
+		switchCtx, cancel := context.WithTimeout(context.Background(), breakCheckTimeout)
+		defer cancel()
+
+		_, err := sc.ExecContext(switchCtx, `ALTER SESSION SET CONTAINER = "`+current+`"`)
+		if err != nil {
+			// Make database/sql discard the session instead of returning it to the pool.
+			_ = sc.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}()
+
+	return f()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// oraCodeRgx matches the code of an ORA error in an error message.
+var oraCodeRgx = regexp.MustCompile(`ORA-[0-9]{5}`)
+
+// errorValueKeys are the keys which return errors as values when ErrorsAsValue is enabled.
+var errorValueKeys = map[string]bool{
+	keyCustomQuery:          true,
+	keyCustomQueryContainer: true,
+	keyCustomQueryPDBs:      true,
+}
+
+// errorCategoryNames are the names error categories are reported with in error values.
+var errorCategoryNames = []struct {
+	category zbxerr.ZabbixError
+	name     string
+}{
+	{errorAuthFailed, "auth_failed"},
+	{errorAccountLocked, "account_locked"},
+	{errorPasswordExpired, "password_expired"},
+	{errorConnectionLost, "connection_lost"},
+	{errorInsufficientPrivileges, "insufficient_privileges"},
+	{errorInvalidSQL, "invalid_sql"},
+	{errorQueryTimeout, "timeout"},
+	{errorResourceBusy, "resource_busy"},
+}
+
+// errorInfo is the error object of an error value.
+type errorInfo struct {
+	Code     string `json:"code,omitempty"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// newErrorInfo describes err, msg is its message as it may be reported, e.g. with the passwords redacted.
+// The category is the one classifyError wrapped err into, "other" if there is none.
+func newErrorInfo(err error, msg string) *errorInfo {
+	info := &errorInfo{Code: oraCodeRgx.FindString(msg), Category: "other", Message: msg}
+
+	for _, c := range errorCategoryNames {
+		if hasCategory(err, c.category) {
+			info.Category = c.name
+
+			break
+		}
+	}
+
+	return info
+}
+
+// errorValue returns a failure as a JSON value: {"error":{"code":"ORA-00942","category":"invalid_sql","message":"..."}}.
+func errorValue(err error, msg string) (interface{}, error) {
+	res, err := json.Marshal(map[string]*errorInfo{"error": newErrorInfo(err, msg)})
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(res), nil
+}
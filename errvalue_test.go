@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+func TestErrorValue(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			"categorized",
+			errorInvalidSQL.Wrap(errors.New("ORA-00942: table or view does not exist")),
+			`{"error":{"code":"ORA-00942","category":"invalid_sql",` +
+				`"message":"Invalid SQL: ORA-00942: table or view does not exist."}}`,
+		},
+		{
+			"timeout",
+			errorQueryTimeout.Wrap(errors.New("call timeout of 5s exceeded")),
+			`{"error":{"category":"timeout","message":"Query timed out: call timeout of 5s exceeded."}}`,
+		},
+		{
+			"other",
+			zbxerr.ErrorCannotFetchData.Wrap(errors.New("ORA-01722: invalid number")),
+			`{"error":{"code":"ORA-01722","category":"other","message":"Cannot fetch data: ORA-01722: invalid number."}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := errorValue(tt.err, tt.err.Error())
+			if err != nil {
+				t.Fatalf("errorValue() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("errorValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
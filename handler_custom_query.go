@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"strings"
+	"time"
 
 	"git.zabbix.com/ap/plugin-support/zbxerr"
 )
@@ -17,30 +18,60 @@ func customQueryHandler(
 	params map[string]string, extraParams ...string) (interface{}, error) {
 	p.Tracef("[customQueryHandler] begin")
 
-	query := params["Query"]
-	queryArgs := make([]interface{}, len(extraParams))
-	for i, v := range extraParams {
-		queryArgs[i] = v
+	records, err := fetchRecords(p, ctx, conn, params["Query"], extraParams...)
+	if err != nil {
+		return nil, err
 	}
 
+	// JSON marshaling
+	var data []string
+
+	for _, record := range records {
+		p.Tracef("[customQueryHandler] convert results to json")
+		jsonRes, _ := json.Marshal(record)
+		p.Tracef("[customQueryHandler] append to data")
+		data = append(data, strings.TrimSpace(string(jsonRes)))
+		p.Tracef("[customQueryHandler] done appending")
+	}
+
+	return "[" + strings.Join(data, ",") + "]", nil
+}
+
+// fetchRecords executes a query and returns its rows as column name to value maps.
+func fetchRecords(p *Plugin, ctx context.Context, conn OraClient, query string,
+	extraParams ...string) ([]map[string]interface{}, error) {
+	q := &namedQuery{text: query}
+	if err := q.parseDirectives(); err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	queryArgs, err := parseBinds(extraParams, q.typedBinds)
+	if err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	query, queryArgs = convertBinds(query, queryArgs)
+
 	p.Tracef("[customQueryHandler] before execute query")
+	start := time.Now()
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+
+	defer func() { p.fetchTimed(info, len(queryArgs), time.Since(start)) }()
+
 	rows, err := conn.Query(ctx, query, queryArgs...)
 	if err != nil {
 		p.Tracef("[customQueryHandler] error executing query")
 		p.Tracef("[customQueryHandler] error: %v", err)
-		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
 	}
 	defer rows.Close()
 
-	// JSON marshaling
-	var data []string
-
 	p.Tracef("[customQueryHandler] get columns")
 	columns, err := rows.Columns()
 	if err != nil {
 		p.Tracef("[customQueryHandler] error get columns")
 		p.Tracef("[customQueryHandler] error: %v", err)
-		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
 	}
 
 	values := make([]interface{}, len(columns))
@@ -50,7 +81,7 @@ func customQueryHandler(
 		valuePointers[i] = &values[i]
 	}
 
-	results := make(map[string]interface{})
+	var records []map[string]interface{}
 
 	p.Tracef("[customQueryHandler] begin read recordset")
 	for rows.Next() {
@@ -65,21 +96,27 @@ func customQueryHandler(
 				return nil, zbxerr.ErrorEmptyResult.Wrap(err)
 			}
 
-			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+			return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
 		}
 
 		p.Tracef("[customQueryHandler] fill results")
+		results := make(map[string]interface{}, len(columns))
 		for i, value := range values {
 			results[columns[i]] = value
 		}
 
-		p.Tracef("[customQueryHandler] convert results to json")
-		jsonRes, _ := json.Marshal(results)
-		p.Tracef("[customQueryHandler] append to data")
-		data = append(data, strings.TrimSpace(string(jsonRes)))
-		p.Tracef("[customQueryHandler] done appending")
+		records = append(records, results)
 	}
 	p.Tracef("[customQueryHandler] end read recordset")
 
-	return "[" + strings.Join(data, ",") + "]", nil
+	// A call broken on timeout ends the iteration early, it is reported by rows.Err.
+	if err = rows.Err(); err != nil {
+		return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
+	}
+
+	if info != nil {
+		info.rows += len(records)
+	}
+
+	return records, nil
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// containerQueryHandler executes custom user queries in a pluggable database
+func containerQueryHandler(
+	p *Plugin,
+	ctx context.Context, conn OraClient,
+	params map[string]string, extraParams ...string) (interface{}, error) {
+	p.Tracef("[containerQueryHandler] switching to container %s", params["Container"])
+
+	var (
+		res      interface{}
+		queryErr error
+	)
+
+	err := conn.InContainer(ctx, params["Container"], func(client OraClient) error {
+		res, queryErr = customQueryHandler(p, ctx, client, params, extraParams...)
+
+		return nil
+	})
+	if err != nil {
+		p.Tracef("[containerQueryHandler] error switching container: %v", err)
+		return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
+	}
+
+	return res, queryErr
+}
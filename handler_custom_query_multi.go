@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const sessionColumn = "SESSION"
+
+// sessionError is a failure of a query in one of the sessions of a fan-out.
+type sessionError struct {
+	Session string `json:"session"`
+	Error   string `json:"error"`
+}
+
+// multiQueryResult is the result of a query executed in several sessions.
+type multiQueryResult struct {
+	Data   []map[string]interface{} `json:"data"`
+	Errors []sessionError           `json:"errors"`
+}
+
+// multiQueryHandler executes custom user queries in every session matching a pattern in parallel.
+// Rows of all sessions are returned together, each with the SESSION it comes from,
+// and a failing session is reported next to them instead of failing the whole item.
+func multiQueryHandler(p *Plugin, itemID uint64, params map[string]string, extraParams ...string) (interface{}, error) {
+	pattern := params["Sessions"]
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	var names []string
+
+	for name := range p.options.Sessions {
+		if ok, _ := path.Match(pattern, name); ok {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(fmt.Errorf("no session matches %q", pattern))
+	}
+
+	sort.Strings(names)
+
+	records := make([][]map[string]interface{}, len(names))
+	errs := make([]error, len(names))
+	limit := make(chan struct{}, p.options.FanOutConcurrency)
+
+	var wg sync.WaitGroup
+
+	for i, name := range names {
+		wg.Add(1)
+
+		go func(i int, name string) {
+			defer wg.Done()
+
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			p.Tracef("[multiQueryHandler] executing query in session %s", name)
+			records[i], errs[i] = p.querySession(itemID, name, params["Query"], extraParams...)
+		}(i, name)
+	}
+
+	wg.Wait()
+
+	res := multiQueryResult{Data: []map[string]interface{}{}, Errors: []sessionError{}}
+
+	for i, name := range names {
+		if errs[i] != nil {
+			res.Errors = append(res.Errors, sessionError{Session: name, Error: errs[i].Error()})
+			continue
+		}
+
+		for _, r := range records[i] {
+			r[sessionColumn] = name
+		}
+
+		res.Data = append(res.Data, records[i]...)
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+// sessionQueryParams returns the raw zoracle.custom.query parameters of a query in a session.
+// The connection parameters following the session name are left empty, the session provides them.
+func sessionQueryParams(session, query string, extraParams []string) []string {
+	return append([]string{session, "", "", "", query}, extraParams...)
+}
+
+// querySession executes a custom query in a session the same way zoracle.custom.query does
+// and returns its rows. Errors are always returned as errors, multiQueryHandler lists them itself. Numbers are kept as they were formatted by the database.
+// The statements are tagged with itemID, the item of the whole fan-out.
+func (p *Plugin) querySession(itemID uint64, session, query string,
+	extraParams ...string) ([]map[string]interface{}, error) {
+	res, err := p.export(keyCustomQuery, sessionQueryParams(session, query, extraParams), itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(res.(string))))
+	decoder.UseNumber()
+
+	if err = decoder.Decode(&records); err != nil {
+		return nil, zbxerr.ErrorCannotUnmarshalJSON.Wrap(err)
+	}
+
+	return records, nil
+}
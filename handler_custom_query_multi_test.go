@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSessionQueryParams(t *testing.T) {
+	options := PluginOptions{Sessions: map[string]Session{
+		"prod": {URI: "tcp://db:1521", User: "zabbix", Password: "secret", Service: "ORCL"},
+	}}
+
+	params, extraParams, err := metrics[keyCustomQuery].EvalParams(
+		sessionQueryParams("prod", "SELECT :1 FROM DUAL", []string{"x"}), options.keySessions())
+	if err != nil {
+		t.Fatalf("EvalParams() error = %v", err)
+	}
+
+	want := map[string]string{
+		"URI": "tcp://db:1521", "User": "zabbix", "Password": "secret", "Service": "ORCL",
+		"Query": "SELECT :1 FROM DUAL",
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("EvalParams() params = %v, want %v", params, want)
+	}
+
+	if !reflect.DeepEqual(extraParams, []string{"x"}) {
+		t.Errorf("EvalParams() extraParams = %q, want [x]", extraParams)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const conNameColumn = "CON_NAME"
+
+// pdbsQueryHandler executes custom user queries in every open pluggable database
+// and returns all rows together, each with the CON_NAME of the database it comes from.
+func pdbsQueryHandler(
+	p *Plugin,
+	ctx context.Context, conn OraClient,
+	params map[string]string, extraParams ...string) (interface{}, error) {
+	query := &namedQuery{text: params["Query"]}
+	if err := query.parseDirectives(); err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	var (
+		records []map[string]interface{}
+		err     error
+	)
+
+	if query.containers {
+		records, err = fetchContainersRecords(p, ctx, conn, query.text, extraParams...)
+	} else {
+		records, err = fetchPDBsRecords(p, ctx, conn, query.text, extraParams...)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if records == nil {
+		records = []map[string]interface{}{}
+	}
+
+	jsonRes, err := json.Marshal(records)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+// fetchPDBsRecords runs a query in every open pluggable database by switching the container.
+func fetchPDBsRecords(p *Plugin, ctx context.Context, conn OraClient, query string,
+	extraParams ...string) ([]map[string]interface{}, error) {
+	names, err := fetchColumn(ctx, conn, `SELECT name FROM v$pdbs `+
+		`WHERE open_mode LIKE 'READ%' AND name <> 'PDB$SEED' ORDER BY con_id`)
+	if err != nil {
+		return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
+	}
+
+	var records []map[string]interface{}
+
+	for _, name := range names {
+		p.Tracef("[pdbsQueryHandler] executing query in %s", name)
+
+		var (
+			pdbRecords []map[string]interface{}
+			queryErr   error
+		)
+
+		err = conn.InContainer(ctx, name, func(client OraClient) error {
+			pdbRecords, queryErr = fetchRecords(p, ctx, client, query, extraParams...)
+
+			return nil
+		})
+		if err != nil {
+			return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
+		}
+
+		if queryErr != nil {
+			return nil, queryErr
+		}
+
+		for _, r := range pdbRecords {
+			r[conNameColumn] = name
+		}
+
+		records = append(records, pdbRecords...)
+	}
+
+	return records, nil
+}
+
+// fetchContainersRecords runs a CONTAINERS() query once in the root container
+// and translates the CON_ID column of its rows to container names.
+func fetchContainersRecords(p *Plugin, ctx context.Context, conn OraClient, query string,
+	extraParams ...string) ([]map[string]interface{}, error) {
+	rows, err := conn.Query(ctx, `SELECT con_id, name FROM v$containers`)
+	if err != nil {
+		return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+
+	for rows.Next() {
+		var id, name string
+		if err = rows.Scan(&id, &name); err != nil {
+			return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
+		}
+
+		names[id] = name
+	}
+
+	// A loop broken off, e.g. by the timeout, would leave rows without their CON_NAME.
+	if err = rows.Err(); err != nil {
+		return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
+	}
+
+	records, err := fetchRecords(p, ctx, conn, query, extraParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range records {
+		id, ok := r["CON_ID"]
+		if !ok {
+			return nil, zbxerr.ErrorCannotParseResult.Wrap(errors.New("query declares containers but returns no CON_ID column"))
+		}
+
+		r[conNameColumn] = names[fmt.Sprint(id)]
+	}
+
+	return records, nil
+}
+
+// fetchColumn returns the first column of all rows of a query.
+func fetchColumn(ctx context.Context, conn OraClient, query string, args ...interface{}) ([]string, error) {
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+
+	for rows.Next() {
+		var v string
+		if err = rows.Scan(&v); err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, rows.Err()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/uri"
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+	"github.com/godror/godror"
+)
+
+const maxLatencySamples = 20
+
+// latencyStats summarizes measurements in milliseconds.
+type latencyStats struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// latencyResult is the result of zoracle.latency. TCPConnect is missing when the address of the listener
+// is not known, e.g. for sessions with a ConnectString.
+type latencyResult struct {
+	Samples    int           `json:"samples"`
+	TCPConnect *latencyStats `json:"tcp_connect_ms,omitempty"`
+	Session    *latencyStats `json:"session_ms"`
+	RoundTrip  *latencyStats `json:"round_trip_ms"`
+}
+
+// latencyHandler measures the TCP connect to the listener, the establishment of a new session
+// and the round trip of a trivial call on the cached connection, each Samples times.
+// A slow listener shows in the first two, a stalled session only in the last one.
+func latencyHandler(p *Plugin, uri uri.URI, opts connOptions,
+	params map[string]string, _ ...string) (interface{}, error) {
+	samples, err := strconv.Atoi(params["Samples"])
+	if err != nil || samples < 1 || samples > maxLatencySamples {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(fmt.Errorf("samples must be between 1 and %d", maxLatencySamples))
+	}
+
+	connMgr := p.connMgr
+	if err = connMgr.begin(); err != nil {
+		return nil, err
+	}
+	defer connMgr.end()
+
+	conn, err := connMgr.GetConnection(p, uri, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := latencyResult{Samples: samples}
+
+	if uri.GetParam("connect") == "" {
+		addr := net.JoinHostPort(uri.Host(), uri.Port())
+
+		res.TCPConnect, err = measure(samples, func() error { return dialListener(addr, connMgr.connectTimeout) })
+		if err != nil {
+			return nil, classifyError(err, zbxerr.ErrorConnectionFailed)
+		}
+	}
+
+	if res.Session, err = measure(samples, func() error { return connMgr.connectThrowaway(uri, opts) }); err != nil {
+		return nil, err
+	}
+
+	if res.RoundTrip, err = measure(samples, func() error { return roundTrip(conn) }); err != nil {
+		if isLoginFailure(err) {
+			return nil, connMgr.sessionError(uri, conn, err)
+		}
+
+		return nil, classifyError(err, zbxerr.ErrorCannotFetchData)
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+// measure runs f samples times and summarizes its durations. It stops at the first failure.
+func measure(samples int, f func() error) (*latencyStats, error) {
+	var stats latencyStats
+
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+
+		if err := f(); err != nil {
+			return nil, err
+		}
+
+		ms := milliseconds(time.Since(start))
+
+		if i == 0 || ms < stats.Min {
+			stats.Min = ms
+		}
+
+		if ms > stats.Max {
+			stats.Max = ms
+		}
+
+		stats.Avg += ms / float64(samples)
+	}
+
+	return &stats, nil
+}
+
+// dialListener opens and closes a TCP connection to addr.
+func dialListener(addr string, timeout time.Duration) error {
+	c, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+
+	return c.Close()
+}
+
+// connectThrowaway opens a standalone session with the settings of a cached connection and closes it.
+// Only the login is done, the checks and init statements of a new connection would add their own round trips.
+// The login counts against the login breaker like any other.
+func (c *ConnManager) connectThrowaway(uri uri.URI, opts connOptions) error {
+	password, err := c.resolvePassword(opts)
+	if err != nil {
+		return zbxerr.ErrorConnectionFailed.Wrap(err)
+	}
+
+	opts.password = password
+	opts.pool = false
+
+	if err = c.logins.check(uri, secretHash(opts.password)); err != nil {
+		return err
+	}
+
+	connParams, opts, err := c.connectionParams(uri, opts)
+	if err != nil {
+		return zbxerr.ErrorConnectionFailed.Wrap(err)
+	}
+
+	client := sql.OpenDB(godror.NewConnector(connParams))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(c.ctx, opts.connectTimeout)
+	defer cancel()
+
+	sc, err := client.Conn(ctx)
+	if err != nil {
+		return c.connectError(uri, secretHash(opts.password), err)
+	}
+
+	return sc.Close()
+}
+
+// roundTrip executes a trivial call on the connection.
+func roundTrip(conn *OraConn) error {
+	ctx, cancel := context.WithTimeout(conn.ctx, conn.callTimeout)
+	defer cancel()
+
+	var res int
+
+	return conn.client.QueryRowContext(ctx, pingQuery).Scan(&res)
+}
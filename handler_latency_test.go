@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/uri"
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+	"github.com/omeid/go-yarn"
+)
+
+func TestMeasure(t *testing.T) {
+	calls := 0
+
+	stats, err := measure(3, func() error {
+		calls++
+		time.Sleep(time.Duration(calls) * time.Millisecond)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("measure() error = %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("measure() called f %d times, want 3", calls)
+	}
+
+	if stats.Min < 1 || stats.Max < 3 || stats.Min > stats.Avg || stats.Avg > stats.Max {
+		t.Errorf("measure() = %+v, want 1 <= min <= avg <= max, 3 <= max", *stats)
+	}
+}
+
+func TestMeasureFailure(t *testing.T) {
+	calls := 0
+	fail := errors.New("refused")
+
+	_, err := measure(5, func() error {
+		calls++
+		if calls == 2 {
+			return fail
+		}
+
+		return nil
+	})
+	if !errors.Is(err, fail) {
+		t.Errorf("measure() error = %v, want %v", err, fail)
+	}
+
+	if calls != 2 {
+		t.Errorf("measure() called f %d times, want 2", calls)
+	}
+}
+
+func TestLatencyHandlerSamples(t *testing.T) {
+	for _, samples := range []string{"0", "21", "many"} {
+		_, err := latencyHandler(&Plugin{}, uri.URI{}, connOptions{}, map[string]string{"Samples": samples})
+		if !hasCategory(err, zbxerr.ErrorInvalidParams) {
+			t.Errorf("latencyHandler() with %s samples error = %v, want invalid parameters", samples, err)
+		}
+	}
+}
+
+func TestConnManager_connectThrowaway(t *testing.T) {
+	c := NewConnManager(time.Minute, time.Second, time.Second, time.Minute, time.Minute, time.Minute, 1,
+		yarn.NewFromMap(map[string]string{}))
+	defer c.Destroy(time.Second)
+
+	u, err := uri.NewWithCreds("tcp://127.0.0.1:1?service=ORCL", "zabbix", "", uriDefaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := connOptions{password: "secret"}
+
+	err = c.connectThrowaway(*u, opts)
+	if !hasCategory(err, zbxerr.ErrorConnectionFailed) {
+		t.Fatalf("connectThrowaway() error = %v, want connection failed", err)
+	}
+
+	loginErr := errors.New("Authentication failed: ORA-01017: invalid username/password; logon denied")
+	c.logins.fail(*u, secretHash(opts.password), loginErr)
+
+	if err = c.connectThrowaway(*u, opts); !errors.Is(err, loginErr) {
+		t.Errorf("connectThrowaway() with suspended logins error = %v, want %v", err, loginErr)
+	}
+}
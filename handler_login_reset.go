@@ -0,0 +1,17 @@
+package main
+
+import (
+	"git.zabbix.com/ap/plugin-support/uri"
+)
+
+// loginResetHandler allows logins to a URI suspended by the login breaker.
+// It must not try to log in itself.
+func loginResetHandler(p *Plugin, uri uri.URI, _ connOptions, _ map[string]string, _ ...string) (interface{}, error) {
+	if p.connMgr.logins.reset(uri) {
+		p.Infof("logins to %s allowed again", uri.Addr())
+
+		return 1, nil
+	}
+
+	return 0, nil
+}
@@ -10,6 +10,9 @@ const (
 	pingOk     = 1
 )
 
+// pingQuery is the trivial call checking a session is alive.
+var pingQuery = fmt.Sprintf("SELECT %d FROM DUAL", pingOk)
+
 // pingHandler queries 'SELECT 1 FROM DUAL' and returns pingOk if a connection is alive or pingFailed otherwise.
 func pingHandler(
 	//mn
@@ -17,7 +20,7 @@ func pingHandler(
 	ctx context.Context, conn OraClient, params map[string]string, _ ...string) (interface{}, error) {
 	var res int
 
-	row, err := conn.QueryRow(ctx, fmt.Sprintf("SELECT %d FROM DUAL", pingOk))
+	row, err := conn.QueryRow(ctx, pingQuery)
 	if err != nil {
 		return pingFailed, nil
 	}
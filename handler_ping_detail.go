@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// instanceQuery reads the state of the instance reported by zoracle.ping.detail.
+const instanceQuery = `SELECT i.instance_name, d.open_mode, d.database_role FROM v$instance i, v$database d`
+
+// connectLatencyKey is the context key of the time Export spent getting the connection.
+type connectLatencyKey struct{}
+
+// pingDetail is the health of a database returned by zoracle.ping.detail.
+type pingDetail struct {
+	Alive          int        `json:"alive"`
+	ConnectLatency float64    `json:"connect_latency_ms"`
+	QueryLatency   *float64   `json:"query_latency_ms,omitempty"`
+	Instance       string     `json:"instance,omitempty"`
+	OpenMode       string     `json:"open_mode,omitempty"`
+	DatabaseRole   string     `json:"database_role,omitempty"`
+	Version        string     `json:"version,omitempty"`
+	Error          *errorInfo `json:"error,omitempty"`
+}
+
+// pingDetailHandler measures the round trip of 'SELECT 1 FROM DUAL' and reads the state of the instance.
+// Failures are reported in the result, so the item stays supported when the database is down.
+func pingDetailHandler(
+	p *Plugin,
+	ctx context.Context, conn OraClient, _ map[string]string, _ ...string) (interface{}, error) {
+	connectLatency, _ := ctx.Value(connectLatencyKey{}).(time.Duration)
+	detail := pingDetail{ConnectLatency: milliseconds(connectLatency), Version: conn.ServerVersion()}
+
+	start := time.Now()
+
+	var res int
+
+	row, err := conn.QueryRow(ctx, pingQuery)
+	if err == nil {
+		err = row.Scan(&res)
+	}
+
+	if err != nil {
+		return detail.fail(p, classifyCallError(ctx, err, zbxerr.ErrorCannotFetchData))
+	}
+
+	queryLatency := milliseconds(time.Since(start))
+	detail.QueryLatency = &queryLatency
+	detail.Alive = pingOk
+
+	row, err = conn.QueryRow(ctx, instanceQuery)
+	if err == nil {
+		err = row.Scan(&detail.Instance, &detail.OpenMode, &detail.DatabaseRole)
+	}
+
+	if err != nil {
+		// The session is alive, the account just cannot read the views.
+		err = classifyCallError(ctx, err, zbxerr.ErrorCannotFetchData)
+		detail.Error = newErrorInfo(err, p.redactor.redact(err.Error()))
+	}
+
+	return detail.json()
+}
+
+// pingFailureDetail returns the detail of a database Export could not get a session of.
+func (p *Plugin) pingFailureDetail(connectLatency time.Duration, err error) (interface{}, error) {
+	detail := pingDetail{ConnectLatency: milliseconds(connectLatency)}
+
+	return detail.fail(p, err)
+}
+
+// fail reports err in the detail.
+func (d *pingDetail) fail(p *Plugin, err error) (interface{}, error) {
+	p.Debugf("ping failed: %s", err.Error())
+
+	d.Alive = pingFailed
+	d.Error = newErrorInfo(err, p.redactor.redact(err.Error()))
+
+	return d.json()
+}
+
+// json returns the detail as the item value.
+func (d *pingDetail) json() (interface{}, error) {
+	res, err := json.Marshal(d)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(res), nil
+}
+
+// milliseconds converts d to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+func TestPlugin_pingFailureDetail(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			"categorized and redacted",
+			errorAccountLocked.Wrap(errors.New("ORA-28000: The account is locked, password=s3cr3t_pwd")),
+			`{"alive":0,"connect_latency_ms":1.5,"error":{"code":"ORA-28000","category":"account_locked",` +
+				`"message":"Account locked: ORA-28000: The account is locked, password=******"}}`,
+		},
+		{
+			"not an ORA error",
+			classifyError(errors.New("ORA-12170: TNS:Connect timeout occurred"), zbxerr.ErrorConnectionFailed),
+			`{"alive":0,"connect_latency_ms":1.5,"error":{"code":"ORA-12170","category":"other",` +
+				`"message":"Connection failed: ORA-12170: TNS:Connect timeout occurred."}}`,
+		},
+		{
+			"deadline",
+			classifyError(context.DeadlineExceeded, zbxerr.ErrorConnectionFailed),
+			`{"alive":0,"connect_latency_ms":1.5,"error":{"category":"timeout",` +
+				`"message":"Query timed out: context deadline exceeded."}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logger captureLogger
+
+			p := &Plugin{}
+			p.Logger = &logger
+			p.redactor.add("s3cr3t_pwd")
+
+			got, err := p.pingFailureDetail(1500*time.Microsecond, tt.err)
+			if err != nil {
+				t.Fatalf("pingFailureDetail() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("pingFailureDetail() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
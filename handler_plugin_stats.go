@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// pluginStatsHandler returns statistics of the plugin itself, so it can be told whether the plugin is a bottleneck.
+func pluginStatsHandler(p *Plugin, _ uint64, _ map[string]string, _ ...string) (interface{}, error) {
+	jsonRes, err := json.Marshal(p.connMgr.statsSummary())
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+// queryStatsHandler returns the fetch times of every named query and SQL fingerprint,
+// so expensive monitoring queries can be found.
+func queryStatsHandler(p *Plugin, _ uint64, _ map[string]string, _ ...string) (interface{}, error) {
+	jsonRes, err := json.Marshal(p.connMgr.stats.fetchSummaries())
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
@@ -11,7 +11,15 @@ import (
 
 const (
 	keyCustomQuery            = "zoracle.custom.query"
+	keyCustomQueryContainer   = "zoracle.custom.query.container"
+	keyCustomQueryPDBs        = "zoracle.custom.query.pdbs"
+	keyCustomQueryMulti       = "zoracle.custom.query.multi"
 	keyPing                   = "zoracle.ping"
+	keyPingDetail             = "zoracle.ping.detail"
+	keyLoginReset             = "zoracle.login.reset"
+	keyLatency                = "zoracle.latency"
+	keyPluginStats            = "zoracle.plugin.stats"
+	keyQueryStats             = "zoracle.query.stats"
 )
 
 // handlerFunc defines an interface must be implemented by handlers.
@@ -21,13 +29,54 @@ type handlerFunc func(
 	ctx context.Context, conn OraClient,
 	params map[string]string, extraParams ...string) (res interface{}, err error)
 
+// pluginHandlerFunc defines an interface must be implemented by handlers which do not work on a single connection.
+// itemID is the item the key is collected for, zero if it is not known.
+type pluginHandlerFunc func(p *Plugin, itemID uint64, params map[string]string,
+	extraParams ...string) (res interface{}, err error)
+
+// getPluginHandlerFunc returns a pluginHandlerFunc related to a given key.
+func getPluginHandlerFunc(key string) pluginHandlerFunc {
+	switch key {
+	case keyCustomQueryMulti:
+		return multiQueryHandler
+	case keyPluginStats:
+		return pluginStatsHandler
+	case keyQueryStats:
+		return queryStatsHandler
+	default:
+		return nil
+	}
+}
+
+// connHandlerFunc defines an interface must be implemented by handlers which manage connections themselves.
+type connHandlerFunc func(p *Plugin, uri uri.URI, opts connOptions,
+	params map[string]string, extraParams ...string) (res interface{}, err error)
+
+// getConnHandlerFunc returns a connHandlerFunc related to a given key.
+func getConnHandlerFunc(key string) connHandlerFunc {
+	switch key {
+	case keyLoginReset:
+		return loginResetHandler
+	case keyLatency:
+		return latencyHandler
+	default:
+		return nil
+	}
+}
+
 // getHandlerFunc returns a handlerFunc related to a given key.
 func getHandlerFunc(key string) handlerFunc {
 	switch key {
 	case keyCustomQuery:
 		return customQueryHandler
+	case keyCustomQueryContainer:
+		return containerQueryHandler
+	case keyCustomQueryPDBs:
+		return pdbsQueryHandler
 	case keyPing:
 		return pingHandler
+	case keyPingDetail:
+		return pingDetailHandler
 	default:
 		return nil
 	}
@@ -42,7 +91,8 @@ var (
 			WithValidator(uri.URIValidator{Defaults: uriDefaults, AllowedSchemes: []string{"tcp"}})
 	paramUsername = metric.NewConnParam("User", "Oracle user.").WithDefault("")
 	paramPassword = metric.NewConnParam("Password", "User's password.").WithDefault("")
-	paramService  = metric.NewConnParam("Service", "Service name to be used for connection.").
+	paramService  = metric.NewConnParam("Service", "Service name to be used for connection, "+
+			"optionally followed by /instance to pin a RAC instance.").
 			WithDefault("XE")
 )
 
@@ -52,8 +102,42 @@ var metrics = metric.MetricSet{
 			metric.NewParam("Query", "SQL string with custom query ").SetRequired(),
 		}, true),
 
+	keyCustomQueryContainer: metric.New("Returns result of a custom query executed in a pluggable database.",
+		[]*metric.Param{paramURI, paramUsername, paramPassword, paramService,
+			metric.NewParam("Container", "Name of a pluggable database to execute the query in.").SetRequired(),
+			metric.NewParam("Query", "SQL string with custom query ").SetRequired(),
+		}, true),
+
+	keyCustomQueryPDBs: metric.New("Returns result of a custom query executed in every open pluggable database.",
+		[]*metric.Param{paramURI, paramUsername, paramPassword, paramService,
+			metric.NewParam("Query", "SQL string with custom query ").SetRequired(),
+		}, true),
+
+	keyCustomQueryMulti: metric.New("Returns result of a custom query executed in every session matching a pattern.",
+		[]*metric.Param{
+			metric.NewParam("Sessions", "Pattern of session names, e.g. prod*.").SetRequired(),
+			metric.NewParam("Query", "SQL string with custom query ").SetRequired(),
+		}, true),
+
 	keyPing: metric.New("Tests if connection is alive or not.",
 		[]*metric.Param{paramURI, paramUsername, paramPassword, paramService}, false),
+
+	keyPingDetail: metric.New("Returns health of a database as JSON.",
+		[]*metric.Param{paramURI, paramUsername, paramPassword, paramService}, false),
+
+	keyLatency: metric.New("Returns latency of reaching a database as JSON.",
+		[]*metric.Param{paramURI, paramUsername, paramPassword, paramService,
+			metric.NewParam("Samples", "Number of measurements of each kind, 1-20.").WithDefault("1"),
+		}, false),
+
+	keyPluginStats: metric.New("Returns statistics of the plugin itself as JSON.",
+		nil, false),
+
+	keyQueryStats: metric.New("Returns fetch times of custom queries as JSON.",
+		nil, false),
+
+	keyLoginReset: metric.New("Allows logins suspended after repeated login failures.",
+		[]*metric.Param{paramURI, paramUsername, paramPassword, paramService}, false),
 }
 
 func init() {
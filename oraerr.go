@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+	"github.com/godror/godror"
+)
+
+// Categories of database errors. The messages are a part of the item error text,
+// they must stay stable, so triggers can match them.
+var (
+	errorAuthFailed             = zbxerr.New("Authentication failed")
+	errorAccountLocked          = zbxerr.New("Account locked")
+	errorPasswordExpired        = zbxerr.New("Password expired")
+	errorConnectionLost         = zbxerr.New("Connection lost")
+	errorInsufficientPrivileges = zbxerr.New("Insufficient privileges")
+	errorInvalidSQL             = zbxerr.New("Invalid SQL")
+	errorQueryTimeout           = zbxerr.New("Query timed out")
+	errorResourceBusy           = zbxerr.New("Resource busy")
+)
+
+// oraErrorCategories maps ORA error codes to their categories.
+var oraErrorCategories = map[int]zbxerr.ZabbixError{
+	1004: errorAuthFailed, // default username feature not supported
+	1005: errorAuthFailed, // null password given
+	1017: errorAuthFailed, // invalid username/password
+
+	28000: errorAccountLocked,
+
+	28001: errorPasswordExpired,
+	28002: errorPasswordExpired, // the password will expire within days
+
+	1012:  errorConnectionLost, // not logged on
+	1033:  errorConnectionLost, // initialization or shutdown in progress
+	1034:  errorConnectionLost, // ORACLE not available
+	1089:  errorConnectionLost, // immediate shutdown in progress
+	1092:  errorConnectionLost, // instance terminated
+	3113:  errorConnectionLost, // end-of-file on communication channel
+	3114:  errorConnectionLost, // not connected to ORACLE
+	3135:  errorConnectionLost, // connection lost contact
+	12514: errorConnectionLost, // listener does not know of service
+	12528: errorConnectionLost, // all instances are blocking new connections
+	12537: errorConnectionLost, // connection closed
+	12541: errorConnectionLost, // no listener
+	12543: errorConnectionLost, // destination host unreachable
+	12545: errorConnectionLost, // target host or object does not exist
+	12547: errorConnectionLost, // lost contact
+	28547: errorConnectionLost, // connection to server failed
+
+	1031: errorInsufficientPrivileges,
+	1045: errorInsufficientPrivileges, // user lacks CREATE SESSION privilege
+	1924: errorInsufficientPrivileges, // role not granted
+	1994: errorInsufficientPrivileges, // password file missing or disabled
+
+	900:  errorInvalidSQL, // invalid SQL statement
+	904:  errorInvalidSQL, // invalid identifier
+	905:  errorInvalidSQL, // missing keyword
+	906:  errorInvalidSQL, // missing left parenthesis
+	907:  errorInvalidSQL, // missing right parenthesis
+	909:  errorInvalidSQL, // invalid number of arguments
+	911:  errorInvalidSQL, // invalid character
+	917:  errorInvalidSQL, // missing comma
+	918:  errorInvalidSQL, // column ambiguously defined
+	923:  errorInvalidSQL, // FROM keyword not found
+	933:  errorInvalidSQL, // SQL command not properly ended
+	936:  errorInvalidSQL, // missing expression
+	942:  errorInvalidSQL, // table or view does not exist
+	1008: errorInvalidSQL, // not all variables bound
+	1036: errorInvalidSQL, // illegal variable name/number
+	6550: errorInvalidSQL, // PL/SQL compilation error
+
+	// ORA-01013 "user requested cancel" is left out: a call is also cancelled when the plugin stops,
+	// it is a timeout only if the deadline expired, see classifyCallError.
+	3156:  errorQueryTimeout, // OCI call timed out
+	12170: errorQueryTimeout, // connect timeout occurred
+
+	18:    errorResourceBusy, // maximum number of sessions exceeded
+	20:    errorResourceBusy, // maximum number of processes exceeded
+	54:    errorResourceBusy, // resource busy and acquire with NOWAIT
+	4021:  errorResourceBusy, // timeout occurred while waiting to lock object
+	12516: errorResourceBusy, // listener could not find available handler
+	12519: errorResourceBusy, // no appropriate service handler found
+	12520: errorResourceBusy, // listener could not find available handler for requested type of server
+	24418: errorResourceBusy, // cannot open further sessions
+	24457: errorResourceBusy, // session pool could not find a free session in time
+	30006: errorResourceBusy, // resource busy; acquire with WAIT timeout expired
+}
+
+// classifyError wraps err into the category of the database error it carries,
+// or into fallback if it is not a known ORA error.
+// It must be given the error returned by the driver, not one already wrapped by zbxerr.
+func classifyError(err error, fallback zbxerr.ZabbixError) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errorQueryTimeout.Wrap(err)
+	}
+
+	if oraErr, ok := godror.AsOraErr(err); ok {
+		if category, ok := oraErrorCategories[oraErr.Code()]; ok {
+			return category.Wrap(err)
+		}
+	}
+
+	return fallback.Wrap(err)
+}
+
+// classifyCallError is classifyError for the error of a call made with ctx.
+// Whatever the driver returned for a call broken because its deadline expired, it is a timeout.
+func classifyCallError(ctx context.Context, err error, fallback zbxerr.ZabbixError) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return errorQueryTimeout.Wrap(err)
+	}
+
+	return classifyError(err, fallback)
+}
+
+// hasCategory reports whether err was wrapped into category by classifyError.
+// The raw message is compared, the formatted one is capitalized and ends with a period.
+func hasCategory(err error, category zbxerr.ZabbixError) bool {
+	var zbxErr zbxerr.ZabbixError
+	if !errors.As(err, &zbxErr) {
+		return false
+	}
+
+	raw := zbxErr.Raw()
+
+	return raw == category.Raw() || strings.HasPrefix(raw, category.Raw()+": ")
+}
+
+// isTimeout reports whether err was classified as a timeout.
+func isTimeout(err error) bool {
+	return hasCategory(err, errorQueryTimeout)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		category zbxerr.ZabbixError
+	}{
+		{"deadline", context.DeadlineExceeded, errorQueryTimeout},
+		{"wrapped deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), errorQueryTimeout},
+		{"canceled", context.Canceled, zbxerr.ErrorCannotFetchData},
+		{"other", errors.New("boom"), zbxerr.ErrorCannotFetchData},
+		{"context kept", fmt.Errorf(errorInitStatement, "ALTER SESSION SET X = 1", errors.New("boom")),
+			zbxerr.ErrorCannotFetchData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError(tt.err, zbxerr.ErrorCannotFetchData)
+			if !hasCategory(got, tt.category) {
+				t.Errorf("classifyError() = %q, want category %q", got, tt.category.Raw())
+			}
+
+			if want := tt.category.Wrap(tt.err).Error(); got.Error() != want {
+				t.Errorf("classifyError() = %q, want %q", got, want)
+			}
+
+			if isTimeout(got) != (tt.category.Raw() == errorQueryTimeout.Raw()) {
+				t.Errorf("isTimeout(%q) = %v", got, isTimeout(got))
+			}
+		})
+	}
+}
+
+func TestClassifyCallError(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	stopped, stop := context.WithCancel(context.Background())
+	stop()
+
+	cancelled := errors.New("ORA-01013: user requested cancel of current operation")
+
+	if got := classifyCallError(expired, cancelled, zbxerr.ErrorCannotFetchData); !isTimeout(got) {
+		t.Errorf("classifyCallError() = %q, want a timeout when the deadline expired", got)
+	}
+
+	if got := classifyCallError(stopped, cancelled, zbxerr.ErrorCannotFetchData); isTimeout(got) {
+		t.Errorf("classifyCallError() = %q, want no timeout when the call was cancelled", got)
+	}
+}
+
+func TestOraErrorCategories(t *testing.T) {
+	tests := []struct {
+		code int
+		want zbxerr.ZabbixError
+	}{
+		{1017, errorAuthFailed},
+		{28000, errorAccountLocked},
+		{28001, errorPasswordExpired},
+		{3113, errorConnectionLost},
+		{1031, errorInsufficientPrivileges},
+		{942, errorInvalidSQL},
+		{3156, errorQueryTimeout},
+		{54, errorResourceBusy},
+	}
+
+	for _, tt := range tests {
+		got, ok := oraErrorCategories[tt.code]
+		if !ok {
+			t.Errorf("oraErrorCategories[%d] is missing", tt.code)
+
+			continue
+		}
+
+		if got.Error() != tt.want.Error() {
+			t.Errorf("oraErrorCategories[%d] = %q, want %q", tt.code, got.Error(), tt.want.Error())
+		}
+	}
+
+	if _, ok := oraErrorCategories[1013]; ok {
+		t.Error("oraErrorCategories[1013] is set, a cancelled call is not always a timeout")
+	}
+}
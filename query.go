@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// queryDirectivesRgx matches the leading comment declaring query directives: /* zoracle: call_timeout=60 */.
+var queryDirectivesRgx = regexp.MustCompile(`^\s*/\*\s*zoracle:([^*]*)\*/`)
+
+// namedQuery is a query to execute together with the directives declared in it.
+type namedQuery struct {
+	// name of a query file without the extension, empty for queries passed as SQL text.
+	name string
+	text string
+
+	callTimeout time.Duration
+
+	// containers tells the query reads all pluggable databases itself with CONTAINERS() and returns CON_ID.
+	containers bool
+
+	// typedBinds makes the arguments of the query be converted according to their type prefixes, see parseBinds.
+	typedBinds bool
+}
+
+// getQuery returns the query stored as <query>.sql in the queries directory,
+// or treats query as SQL text if there is no such file.
+func (c *ConnManager) getQuery(query string) (*namedQuery, error) {
+	q := &namedQuery{text: query}
+
+	if c.queryStorage != nil {
+		if text, ok := c.queryStorage.Get(query + sqlExt); ok {
+			q.name = query
+			q.text = text
+		}
+	}
+
+	if err := q.parseDirectives(); err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	return q, nil
+}
+
+// parseDirectives reads the key=value pairs of the leading zoracle comment of a query.
+func (q *namedQuery) parseDirectives() error {
+	m := queryDirectivesRgx.FindStringSubmatch(q.text)
+	if m == nil {
+		return nil
+	}
+
+	for _, directive := range strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		key, value, _ := strings.Cut(directive, "=")
+
+		switch strings.ToLower(key) {
+		case "call_timeout":
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return fmt.Errorf("invalid call_timeout %q", value)
+			}
+
+			q.callTimeout = time.Duration(seconds) * time.Second
+		case "containers":
+			if value != "" && value != "1" && !strings.EqualFold(value, "true") {
+				return fmt.Errorf("invalid containers %q", value)
+			}
+
+			q.containers = true
+		case "typed_binds":
+			if value != "" && value != "1" && !strings.EqualFold(value, "true") {
+				return fmt.Errorf("invalid typed_binds %q", value)
+			}
+
+			q.typedBinds = true
+		default:
+			return fmt.Errorf("unknown query directive %q", key)
+		}
+	}
+
+	return nil
+}
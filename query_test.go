@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/omeid/go-yarn"
+)
+
+func TestConnManager_getQuery(t *testing.T) {
+	connMgr := &ConnManager{queryStorage: yarn.NewFromMap(map[string]string{
+		"ts_stats.sql": "/* zoracle: call_timeout=120 */\nSELECT 1 FROM DUAL",
+	})}
+
+	tests := []struct {
+		name            string
+		query           string
+		wantName        string
+		wantCallTimeout time.Duration
+		wantErr         bool
+	}{
+		{"SQL text", "SELECT 1 FROM DUAL", "", 0, false},
+		{"Named query", "ts_stats", "ts_stats", 120 * time.Second, false},
+		{"SQL text with directives", "/* zoracle: call_timeout=5 */ SELECT 1 FROM DUAL", "", 5 * time.Second, false},
+		{"Ordinary comment", "/* call_timeout=5 */ SELECT 1 FROM DUAL", "", 0, false},
+		{"Invalid timeout", "/* zoracle: call_timeout=abc */ SELECT 1 FROM DUAL", "", 0, true},
+		{"Unknown directive", "/* zoracle: timeout=5 */ SELECT 1 FROM DUAL", "", 0, true},
+		{"Typed binds", "/* zoracle: typed_binds, call_timeout=5 */ SELECT :1 FROM DUAL", "", 5 * time.Second, false},
+		{"Invalid typed binds", "/* zoracle: typed_binds=yes */ SELECT :1 FROM DUAL", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := connMgr.getQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConnManager.getQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got.name != tt.wantName || got.callTimeout != tt.wantCallTimeout {
+				t.Errorf("ConnManager.getQuery() = {%q, %s}, want {%q, %s}",
+					got.name, got.callTimeout, tt.wantName, tt.wantCallTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// captureLogger stores every message it receives.
+type captureLogger struct {
+	messages []string
+}
+
+func (l *captureLogger) add(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Tracef(format string, args ...interface{})   { l.add(format, args...) }
+func (l *captureLogger) Debugf(format string, args ...interface{})   { l.add(format, args...) }
+func (l *captureLogger) Warningf(format string, args ...interface{}) { l.add(format, args...) }
+func (l *captureLogger) Infof(format string, args ...interface{})    { l.add(format, args...) }
+func (l *captureLogger) Errf(format string, args ...interface{})     { l.add(format, args...) }
+func (l *captureLogger) Critf(format string, args ...interface{})    { l.add(format, args...) }
+
+func TestRedactor_redact(t *testing.T) {
+	var r redactor
+
+	r.add("s3cr3t_Session", "x", "env:ORA_PWD")
+
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{"Registered secret", "login with s3cr3t_Session failed", "login with ****** failed"},
+		{"Short strings are not registered", "x marks the spot", "x marks the spot"},
+		{"Params map", "map[Password:other_pwd Service:XE]", "map[Password:****** Service:XE]"},
+		{"URI user info", "tcp://zabbix:other_pwd@db:1521", "tcp://zabbix:******@db:1521"},
+		{"Connection string", `user=zabbix password="other pwd" connectString=db`, `user=zabbix password=****** connectString=db`},
+		{"Wallet password", "wallet_password=other_pwd;", "wallet_password=******;"},
+		{"Nothing to redact", "[Export] begin for key : zoracle.ping", "[Export] begin for key : zoracle.ping"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.redact(tt.msg); got != tt.want {
+				t.Errorf("redactor.redact() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_logging(t *testing.T) {
+	const (
+		sessionPwd = "S3ss10n_Pwd"
+		keyPwd     = "K3y_Pwd#1"
+	)
+
+	var logger captureLogger
+
+	p := &Plugin{}
+	p.Logger = &logger
+	p.options.Sessions = map[string]Session{"prod": {URI: "tcp://db:1521", User: "zabbix", Password: sessionPwd}}
+	p.redactor.add(p.options.sessionPasswords()...)
+	p.redactor.add(keyPwd)
+
+	params := map[string]string{"URI": "tcp://db:1521", "User": "zabbix", "Password": keyPwd}
+
+	p.Tracef("[Export] params : %s", params)
+	p.Debugf("session %v", p.options.Sessions)
+	p.Infof("connecting as zabbix/%s", sessionPwd)
+	p.Warningf("%s", "tcp://zabbix:"+keyPwd+"@db:1521")
+	p.Errf(errors.New("ORA-01017: invalid username/password " + keyPwd).Error())
+	p.Critf("%s %s", sessionPwd, keyPwd)
+
+	if len(logger.messages) != 6 {
+		t.Fatalf("expected 6 logged messages, got %d", len(logger.messages))
+	}
+
+	for _, msg := range logger.messages {
+		for _, secret := range []string{sessionPwd, keyPwd} {
+			if strings.Contains(msg, secret) {
+				t.Errorf("logged message %q contains a secret", msg)
+			}
+		}
+	}
+}
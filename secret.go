@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	secretFilePrefix = "file:"
+	secretEnvPrefix  = "env:"
+	secretExecPrefix = "exec:"
+)
+
+// cachedSecret is a resolved password together with the time it must be resolved again.
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// secretCache resolves password indirections and keeps the results for ttl,
+// so a rotated password is picked up without reloading the agent.
+type secretCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	timeout time.Duration
+	entries map[string]cachedSecret
+}
+
+// newSecretCache initializes a secretCache. Commands run by exec: are killed after timeout.
+func newSecretCache(ttl, timeout time.Duration) *secretCache {
+	return &secretCache{
+		ttl:     ttl,
+		timeout: timeout,
+		entries: make(map[string]cachedSecret),
+	}
+}
+
+// isSecretRef reports whether a password is an indirection rather than the password itself.
+func isSecretRef(password string) bool {
+	return strings.HasPrefix(password, secretFilePrefix) ||
+		strings.HasPrefix(password, secretEnvPrefix) ||
+		strings.HasPrefix(password, secretExecPrefix)
+}
+
+// resolve returns the password referenced by ref. Plain passwords are returned as is.
+func (c *secretCache) resolve(ref string) (string, error) {
+	if !isSecretRef(ref) {
+		return ref, nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if s, ok := c.entries[ref]; ok && time.Now().Before(s.expires) {
+		return s.value, nil
+	}
+
+	value, err := readSecret(ref, c.timeout)
+	if err != nil {
+		delete(c.entries, ref)
+
+		return "", err
+	}
+
+	if c.ttl > 0 {
+		c.entries[ref] = cachedSecret{value: value, expires: time.Now().Add(c.ttl)}
+	}
+
+	return value, nil
+}
+
+// readSecret reads the password referenced by ref bypassing the cache.
+// Error messages never contain the secret itself.
+func readSecret(ref string, timeout time.Duration) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretFilePrefix):
+		path := strings.TrimPrefix(ref, secretFilePrefix)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read password file %q: %w", path, err)
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case strings.HasPrefix(ref, secretEnvPrefix):
+		name := strings.TrimPrefix(ref, secretEnvPrefix)
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("password environment variable %q is not set", name)
+		}
+
+		return value, nil
+
+	case strings.HasPrefix(ref, secretExecPrefix):
+		args := strings.Fields(strings.TrimPrefix(ref, secretExecPrefix))
+		if len(args) == 0 {
+			return "", errors.New("password command is empty")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("password command %q failed: %w", args[0], err)
+		}
+
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+
+	return ref, nil
+}
+
+// secretHash returns a digest of a password, so connections can detect its change without keeping it.
+func secretHash(secret string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(secret))
+}
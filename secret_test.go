@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSecretCache_resolve(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "ora.pw")
+
+	if err := os.WriteFile(file, []byte("from_file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("ZORACLE_TEST_PWD", "from_env")
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"Plain password", "secret", "secret", false},
+		{"File", "file:" + file, "from_file", false},
+		{"Missing file", "file:" + filepath.Join(dir, "missing"), "", true},
+		{"Environment variable", "env:ZORACLE_TEST_PWD", "from_env", false},
+		{"Unset environment variable", "env:ZORACLE_TEST_UNSET", "", true},
+		{"Command", "exec:echo from_exec", "from_exec", false},
+		{"Empty command", "exec:", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newSecretCache(time.Minute, time.Second).resolve(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("secretCache.resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("secretCache.resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretCache_resolveTTL(t *testing.T) {
+	t.Setenv("ZORACLE_TEST_PWD", "old")
+
+	cache := newSecretCache(time.Hour, time.Second)
+	if got, _ := cache.resolve("env:ZORACLE_TEST_PWD"); got != "old" {
+		t.Fatalf("secretCache.resolve() = %q, want %q", got, "old")
+	}
+
+	os.Setenv("ZORACLE_TEST_PWD", "new")
+
+	t.Run("Cached value should be returned within TTL", func(t *testing.T) {
+		if got, _ := cache.resolve("env:ZORACLE_TEST_PWD"); got != "old" {
+			t.Errorf("secretCache.resolve() = %q, want %q", got, "old")
+		}
+	})
+
+	t.Run("Value should be resolved again without TTL", func(t *testing.T) {
+		cache.ttl = 0
+		cache.entries = make(map[string]cachedSecret)
+
+		if got, _ := cache.resolve("env:ZORACLE_TEST_PWD"); got != "new" {
+			t.Errorf("secretCache.resolve() = %q, want %q", got, "new")
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"time"
+
+	"github.com/godror/godror"
+)
+
+// breakCheckTimeout is the time a session has to answer a ping after its call was broken.
+const breakCheckTimeout = 5 * time.Second
+
+// sessionClient is an OraClient bound to a single database session, e.g. one switched to a container.
+type sessionClient struct {
+	conn  *sql.Conn
+	owner *OraConn
+
+	// driverConn is kept to break a running call, database/sql holds the session locked until the call returns.
+	driverConn godror.Conn
+
+	mu     sync.Mutex
+	broken bool
+}
+
+// openSession takes a session of the connection dedicated to a single request.
+func (conn *OraConn) openSession(ctx context.Context) (*sessionClient, error) {
+	sc, err := conn.client.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &sessionClient{conn: sc, owner: conn}
+
+	err = sc.Raw(func(dc interface{}) error {
+		c.driverConn, _ = dc.(godror.Conn)
+
+		return nil
+	})
+	if err != nil {
+		sc.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// breakOnTimeout breaks the call running in the session when ctx deadline is exceeded,
+// so the statement does not keep running in the database after the item gave up on it.
+// The returned function stops watching ctx and waits until a break in progress is over,
+// so the session is never broken after it has been released.
+func (c *sessionClient) breakOnTimeout(ctx context.Context) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded || c.driverConn == nil {
+				return
+			}
+
+			c.mu.Lock()
+			c.broken = true
+			c.mu.Unlock()
+
+			_ = c.driverConn.Break()
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// release returns the session to the connection.
+// A broken session is discarded unless it proves to be usable by answering a ping.
+func (c *sessionClient) release() (discarded bool) {
+	c.mu.Lock()
+	broken := c.broken
+	c.mu.Unlock()
+
+	if broken {
+		ctx, cancel := context.WithTimeout(context.Background(), breakCheckTimeout)
+		defer cancel()
+
+		if err := c.conn.PingContext(ctx); err != nil {
+			// Make database/sql close the session instead of returning it to the pool.
+			_ = c.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+			discarded = true
+		}
+	}
+
+	c.conn.Close()
+
+	return
+}
+
+// Query wraps Conn.QueryContext.
+func (c *sessionClient) Query(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	rows, err = c.conn.QueryContext(ctx, query, args...)
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+
+	return
+}
+
+// QueryRow wraps Conn.QueryRowContext.
+func (c *sessionClient) QueryRow(ctx context.Context, query string, args ...interface{}) (row *sql.Row, err error) {
+	row = c.conn.QueryRowContext(ctx, query, args...)
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+
+	return
+}
+
+// WhoAmI returns a current username.
+func (c *sessionClient) WhoAmI() string {
+	return c.owner.WhoAmI()
+}
+
+// ServerVersion returns the version of the database server.
+func (c *sessionClient) ServerVersion() string {
+	return c.owner.ServerVersion()
+}
+
+// InContainer runs f in another container of the same session.
+func (c *sessionClient) InContainer(ctx context.Context, container string, f func(client OraClient) error) error {
+	return inContainer(ctx, c.conn, container, func() error { return f(c) })
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/godror/godror"
+)
+
+// breakingConn is a godror.Conn whose Break takes a while, like a round trip to the server.
+type breakingConn struct {
+	godror.Conn
+
+	breaking chan struct{}
+	broken   chan struct{}
+}
+
+func (c *breakingConn) Break() error {
+	close(c.breaking)
+	time.Sleep(50 * time.Millisecond)
+	close(c.broken)
+
+	return nil
+}
+
+func TestSessionClient_breakOnTimeout(t *testing.T) {
+	dc := &breakingConn{breaking: make(chan struct{}), broken: make(chan struct{})}
+	c := &sessionClient{driverConn: dc}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	stop := c.breakOnTimeout(ctx)
+	<-dc.breaking
+	stop()
+
+	select {
+	case <-dc.broken:
+	default:
+		t.Fatal("breakOnTimeout() stop returned while the session was still being broken")
+	}
+
+	if !c.broken {
+		t.Error("breakOnTimeout() did not mark the session broken")
+	}
+}
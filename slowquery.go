@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// sqlLiteralRgx matches string and number literals, which do not make a statement different.
+	sqlLiteralRgx = regexp.MustCompile(`'(?:[^']|'')*'|\b[0-9]+(?:\.[0-9]+)?\b`)
+
+	sqlSpaceRgx = regexp.MustCompile(`\s+`)
+)
+
+// requestInfoKey is the context key of the requestInfo of a query.
+type requestInfoKey struct{}
+
+// requestInfo describes the request a query runs for in logs, statistics and the audit log.
+type requestInfo struct {
+	session string
+
+	// query is the label of the query, name is set for named queries only.
+	query string
+	name  string
+
+	// rows counts the rows fetched by the request.
+	rows int
+
+	// stats are the statistics of the connection manager the request started with.
+	stats *pluginStats
+}
+
+// queryLabel returns the name of a named query or the fingerprint of SQL text.
+func queryLabel(q *namedQuery) string {
+	if q.name != "" {
+		return q.name
+	}
+
+	return sqlFingerprint(q.text)
+}
+
+// sqlFingerprint identifies a statement regardless of its literals, case and formatting.
+func sqlFingerprint(query string) string {
+	normalized := sqlLiteralRgx.ReplaceAllString(strings.TrimSpace(query), "?")
+	normalized = strings.ToLower(sqlSpaceRgx.ReplaceAllString(normalized, " "))
+	sum := sha256.Sum256([]byte(normalized))
+
+	return "sql:" + hex.EncodeToString(sum[:6])
+}
+
+// fetchTimed records the time a query took to fetch and logs it if it exceeds SlowQueryThreshold.
+func (p *Plugin) fetchTimed(info *requestInfo, binds int, elapsed time.Duration) {
+	if info == nil || info.query == "" {
+		return
+	}
+
+	if info.stats != nil {
+		info.stats.fetch(info.query, elapsed)
+	}
+
+	threshold := time.Duration(p.options.SlowQueryThreshold) * time.Millisecond
+	if threshold > 0 && elapsed > threshold {
+		p.Warningf("slow query: session %s, query %s, %d binds, fetched in %s",
+			info.session, info.query, binds, elapsed.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSqlFingerprint(t *testing.T) {
+	base := sqlFingerprint("SELECT name FROM v$pdbs WHERE con_id = 3 AND open_mode = 'READ WRITE'")
+
+	same := []string{
+		"select name from v$pdbs where con_id = 4 and open_mode = 'MOUNTED'",
+		"  SELECT name\n\tFROM v$pdbs\nWHERE con_id = 3 AND open_mode = 'it''s'",
+	}
+
+	for _, q := range same {
+		if got := sqlFingerprint(q); got != base {
+			t.Errorf("sqlFingerprint(%q) = %s, want %s", q, got, base)
+		}
+	}
+
+	if got := sqlFingerprint("SELECT name FROM v$containers WHERE con_id = 3"); got == base {
+		t.Errorf("sqlFingerprint() of another statement = %s, want a different fingerprint", got)
+	}
+
+	if v2 := sqlFingerprint("SELECT name FROM v2 WHERE con_id = 3 AND open_mode = 'x'"); v2 == base {
+		t.Errorf("sqlFingerprint() must keep digits of identifiers")
+	}
+}
+
+func TestPlugin_fetchTimed(t *testing.T) {
+	var logger captureLogger
+
+	p := &Plugin{}
+	p.Logger = &logger
+	p.options.SlowQueryThreshold = 100
+
+	stats := newPluginStats()
+	info := &requestInfo{session: "prod", query: "ts_stats", stats: stats}
+
+	p.fetchTimed(info, 2, 50*time.Millisecond)
+	p.fetchTimed(info, 2, 250*time.Millisecond)
+	p.fetchTimed(&requestInfo{}, 0, time.Second)
+
+	if len(logger.messages) != 1 {
+		t.Fatalf("logged %d messages, want 1: %q", len(logger.messages), logger.messages)
+	}
+
+	if msg := logger.messages[0]; !strings.Contains(msg, "prod") || !strings.Contains(msg, "ts_stats") ||
+		!strings.Contains(msg, "2 binds") || !strings.Contains(msg, "250ms") {
+		t.Errorf("slow query message = %q", msg)
+	}
+
+	want := fetchSummary{Count: 2, P50: 50, P95: 250, Max: 250}
+	if got := stats.fetchSummaries(); len(got) != 1 || got["ts_stats"] != want {
+		t.Errorf("fetchSummaries() = %+v, want only ts_stats %+v", got, want)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/uri"
+)
+
+// statsWindow is the number of the latest durations percentiles are computed from.
+const statsWindow = 1024
+
+// durationWindow keeps the latest statsWindow durations.
+type durationWindow struct {
+	values []time.Duration
+	next   int
+}
+
+// add records a duration, replacing the oldest one once the window is full.
+func (w *durationWindow) add(d time.Duration) {
+	if len(w.values) < statsWindow {
+		w.values = append(w.values, d)
+		return
+	}
+
+	w.values[w.next] = d
+	w.next = (w.next + 1) % statsWindow
+}
+
+// percentiles returns the durations at the given quantiles in milliseconds, nearest rank.
+func (w *durationWindow) percentiles(quantiles ...float64) []float64 {
+	res := make([]float64, len(quantiles))
+	if len(w.values) == 0 {
+		return res
+	}
+
+	sorted := make([]time.Duration, len(w.values))
+	copy(sorted, w.values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for i, q := range quantiles {
+		rank := int(q*float64(len(sorted))+0.999999) - 1
+		if rank < 0 {
+			rank = 0
+		}
+
+		res[i] = milliseconds(sorted[rank])
+	}
+
+	return res
+}
+
+// requestStats counts requests of a key or a named query.
+type requestStats struct {
+	count    uint64
+	errors   uint64
+	timeouts uint64
+	latency  durationWindow
+}
+
+// fetchStats times the fetches of a query.
+type fetchStats struct {
+	count   uint64
+	max     time.Duration
+	latency durationWindow
+}
+
+// connStats counts connections opened to a URI and closed after KeepAlive.
+type connStats struct {
+	Created uint64 `json:"created"`
+	Evicted uint64 `json:"evicted"`
+}
+
+// pluginStats collects the internals of the plugin reported by zoracle.plugin.stats.
+// It lives as long as the connection manager, so a configuration reload resets it.
+type pluginStats struct {
+	sync.Mutex
+	keys        map[string]*requestStats
+	queries     map[string]*requestStats
+	conns       map[string]*connStats
+	fetches     map[string]*fetchStats
+	cacheHits   uint64
+	cacheMisses uint64
+	inFlight    int
+}
+
+func newPluginStats() *pluginStats {
+	return &pluginStats{
+		keys:    make(map[string]*requestStats),
+		queries: make(map[string]*requestStats),
+		conns:   make(map[string]*connStats),
+		fetches: make(map[string]*fetchStats),
+	}
+}
+
+// uriLabel names a connection in statistics without credentials: the session name or user@host:port/service.
+func uriLabel(u uri.URI) string {
+	if session, _ := getURIParam(u, "session"); session != "" {
+		return session
+	}
+
+	label := u.User() + "@" + u.Addr()
+	if service, _ := getURIParam(u, "service"); service != "" {
+		label += "/" + service
+	}
+
+	return label
+}
+
+// request records a finished request of a key, and of a named query if there is one.
+func (s *pluginStats) request(key, query string, elapsed time.Duration, err error, timedOut bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.keys[key] = s.keys[key].record(elapsed, err, timedOut)
+
+	if query != "" {
+		s.queries[query] = s.queries[query].record(elapsed, err, timedOut)
+	}
+}
+
+// record adds a request to r, creating it if it is nil.
+func (r *requestStats) record(elapsed time.Duration, err error, timedOut bool) *requestStats {
+	if r == nil {
+		r = &requestStats{}
+	}
+
+	r.count++
+	r.latency.add(elapsed)
+
+	if err != nil {
+		r.errors++
+	}
+
+	if timedOut {
+		r.timeouts++
+	}
+
+	return r
+}
+
+// fetch records the time a query took to fetch.
+func (s *pluginStats) fetch(query string, elapsed time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	f, ok := s.fetches[query]
+	if !ok {
+		f = &fetchStats{}
+		s.fetches[query] = f
+	}
+
+	f.count++
+	f.latency.add(elapsed)
+
+	if elapsed > f.max {
+		f.max = elapsed
+	}
+}
+
+// fetchSummary is the report of fetchStats.
+type fetchSummary struct {
+	Count uint64  `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P95   float64 `json:"p95_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// fetchSummaries reports the fetch times of all queries.
+func (s *pluginStats) fetchSummaries() map[string]fetchSummary {
+	s.Lock()
+	defer s.Unlock()
+
+	res := make(map[string]fetchSummary, len(s.fetches))
+
+	for query, f := range s.fetches {
+		p := f.latency.percentiles(0.5, 0.95)
+		res[query] = fetchSummary{Count: f.count, P50: p[0], P95: p[1], Max: milliseconds(f.max)}
+	}
+
+	return res
+}
+
+// connCreated records a connection opened to u.
+func (s *pluginStats) connCreated(u uri.URI) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.conn(u).Created++
+}
+
+// connEvicted records a connection to u closed after KeepAlive.
+func (s *pluginStats) connEvicted(u uri.URI) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.conn(u).Evicted++
+}
+
+func (s *pluginStats) conn(u uri.URI) *connStats {
+	label := uriLabel(u)
+
+	c, ok := s.conns[label]
+	if !ok {
+		c = &connStats{}
+		s.conns[label] = c
+	}
+
+	return c
+}
+
+// cacheLookup records whether a connection was found in the cache.
+func (s *pluginStats) cacheLookup(hit bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	if hit {
+		s.cacheHits++
+	} else {
+		s.cacheMisses++
+	}
+}
+
+// begin and end count requests in progress.
+func (s *pluginStats) begin() {
+	s.Lock()
+	s.inFlight++
+	s.Unlock()
+}
+
+func (s *pluginStats) end() {
+	s.Lock()
+	s.inFlight--
+	s.Unlock()
+}
+
+// requestSummary is the report of requestStats.
+type requestSummary struct {
+	Count    uint64  `json:"count"`
+	Errors   uint64  `json:"errors"`
+	Timeouts uint64  `json:"timeouts"`
+	P50      float64 `json:"p50_ms"`
+	P95      float64 `json:"p95_ms"`
+	P99      float64 `json:"p99_ms"`
+}
+
+// statsSummary is the result of zoracle.plugin.stats.
+type statsSummary struct {
+	Connections  int                       `json:"connections"`
+	InFlight     int                       `json:"in_flight"`
+	CacheHitRate float64                   `json:"cache_hit_rate"`
+	URIs         map[string]connStats      `json:"uris"`
+	Keys         map[string]requestSummary `json:"keys"`
+	Queries      map[string]requestSummary `json:"queries"`
+}
+
+// summary reports the statistics. connections is the number of cached connections.
+func (s *pluginStats) summary(connections int) statsSummary {
+	s.Lock()
+	defer s.Unlock()
+
+	res := statsSummary{
+		Connections: connections,
+		InFlight:    s.inFlight,
+		URIs:        make(map[string]connStats, len(s.conns)),
+		Keys:        summarize(s.keys),
+		Queries:     summarize(s.queries),
+	}
+
+	if lookups := s.cacheHits + s.cacheMisses; lookups > 0 {
+		res.CacheHitRate = float64(s.cacheHits) / float64(lookups)
+	}
+
+	for label, c := range s.conns {
+		res.URIs[label] = *c
+	}
+
+	return res
+}
+
+func summarize(stats map[string]*requestStats) map[string]requestSummary {
+	res := make(map[string]requestSummary, len(stats))
+
+	for name, r := range stats {
+		p := r.latency.percentiles(0.5, 0.95, 0.99)
+		res[name] = requestSummary{
+			Count: r.count, Errors: r.errors, Timeouts: r.timeouts,
+			P50: p[0], P95: p[1], P99: p[2],
+		}
+	}
+
+	return res
+}
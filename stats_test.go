@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDurationWindow_percentiles(t *testing.T) {
+	var w durationWindow
+
+	if got := w.percentiles(0.5); !reflect.DeepEqual(got, []float64{0}) {
+		t.Errorf("percentiles() of an empty window = %v, want [0]", got)
+	}
+
+	for i := 100; i >= 1; i-- {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+
+	if got, want := w.percentiles(0.5, 0.95, 1), []float64{50, 95, 100}; !reflect.DeepEqual(got, want) {
+		t.Errorf("percentiles() = %v, want %v", got, want)
+	}
+}
+
+func TestDurationWindow_add(t *testing.T) {
+	var w durationWindow
+
+	for i := 0; i < statsWindow+10; i++ {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+
+	if len(w.values) != statsWindow {
+		t.Fatalf("window holds %d values, want %d", len(w.values), statsWindow)
+	}
+
+	if got := w.percentiles(0); got[0] != 10 {
+		t.Errorf("oldest value = %v, want 10, older ones must be replaced", got[0])
+	}
+}
+
+func TestPluginStats_summary(t *testing.T) {
+	s := newPluginStats()
+
+	s.request(keyCustomQuery, "ts_stats", time.Millisecond, nil, false)
+	s.request(keyCustomQuery, "", 3*time.Millisecond, errors.New("failed"), true)
+	s.cacheLookup(true)
+	s.cacheLookup(true)
+	s.cacheLookup(false)
+	s.begin()
+
+	got := s.summary(2)
+
+	if got.Connections != 2 || got.InFlight != 1 {
+		t.Errorf("summary() connections = %d, in flight = %d, want 2 and 1", got.Connections, got.InFlight)
+	}
+
+	if got.CacheHitRate < 0.66 || got.CacheHitRate > 0.67 {
+		t.Errorf("summary() cache hit rate = %v, want 2/3", got.CacheHitRate)
+	}
+
+	want := requestSummary{Count: 2, Errors: 1, Timeouts: 1, P50: 1, P95: 3, P99: 3}
+	if got.Keys[keyCustomQuery] != want {
+		t.Errorf("summary() key = %+v, want %+v", got.Keys[keyCustomQuery], want)
+	}
+
+	if q := got.Queries["ts_stats"]; q.Count != 1 || len(got.Queries) != 1 {
+		t.Errorf("summary() queries = %+v, want only ts_stats counted once", got.Queries)
+	}
+}
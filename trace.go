@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/godror/godror"
+)
+
+const (
+	traceClientInfo = "zbx_monitor"
+
+	// maxTraceTagLen is the longest MODULE, ACTION or CLIENT_IDENTIFIER the database keeps.
+	maxTraceTagLen = 64
+)
+
+// withCallTraceTag tags the calls made with ctx, so v$session and ASH tell which item runs a statement.
+func withCallTraceTag(ctx context.Context, key string, itemID uint64, action string) context.Context {
+	return godror.ContextWithTraceTag(ctx, callTraceTag(key, itemID, action))
+}
+
+// callTraceTag returns the trace tag of a call: ACTION is the named query or SQL fingerprint,
+// or the key if there is no query, and CLIENT_IDENTIFIER is the key followed by the item id if it is known.
+func callTraceTag(key string, itemID uint64, action string) godror.TraceTag {
+	if action == "" {
+		action = key
+	}
+
+	clientID := key
+	if itemID != 0 {
+		clientID += " itemid=" + strconv.FormatUint(itemID, 10)
+	}
+
+	return godror.TraceTag{
+		ClientInfo:       traceClientInfo,
+		Module:           godror.DriverName,
+		Action:           truncate(action, maxTraceTagLen),
+		ClientIdentifier: truncate(clientID, maxTraceTagLen),
+	}
+}
+
+// truncate cuts s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+
+	return s
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/godror/godror"
+)
+
+func TestCallTraceTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		itemID uint64
+		action string
+		want   godror.TraceTag
+	}{
+		{"named query", 42, "ts_stats",
+			godror.TraceTag{Action: "ts_stats", ClientIdentifier: keyCustomQuery + " itemid=42"}},
+		{"no query", 0, "",
+			godror.TraceTag{Action: keyCustomQuery, ClientIdentifier: keyCustomQuery}},
+		{"long action", 0, strings.Repeat("a", 100),
+			godror.TraceTag{Action: strings.Repeat("a", maxTraceTagLen), ClientIdentifier: keyCustomQuery}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := callTraceTag(keyCustomQuery, tt.itemID, tt.action)
+
+			tt.want.ClientInfo = traceClientInfo
+			tt.want.Module = godror.DriverName
+
+			if got != tt.want {
+				t.Errorf("callTraceTag() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
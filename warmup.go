@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// warmupConcurrency limits the number of sessions connected at once by warmup.
+const warmupConcurrency = 4
+
+// warmup connects to every configured session in the background, so the first poll of items
+// does not pay the connection cost, and reports unreachable sessions right after the start.
+func (p *Plugin) warmup(connMgr *ConnManager) {
+	names := make([]string, 0, len(p.options.Sessions))
+	for name := range p.options.Sessions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	limit := make(chan struct{}, warmupConcurrency)
+
+	var wg sync.WaitGroup
+
+	for _, name := range names {
+		wg.Add(1)
+
+		go func(name string) {
+			defer wg.Done()
+
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			if err := p.connectSession(connMgr, name); err != nil {
+				p.Warningf("[warmup] session %q is unreachable: %s", name, err)
+				return
+			}
+
+			p.Debugf("[warmup] session %q is connected", name)
+		}(name)
+	}
+
+	wg.Wait()
+}
+
+// connectSession opens a connection of a configured session the same way items do.
+func (p *Plugin) connectSession(connMgr *ConnManager, name string) error {
+	if err := connMgr.begin(); err != nil {
+		return err
+	}
+	defer connMgr.end()
+
+	sessionName, session := p.options.getSession([]string{name})
+
+	params, _, err := metrics[keyPing].EvalParams([]string{name}, p.options.keySessions())
+	if err != nil {
+		return err
+	}
+
+	uri, opts, err := newConnParams(&p.options, params, sessionName, session)
+	if err != nil {
+		return err
+	}
+
+	_, err = connMgr.GetConnection(p, *uri, opts)
+
+	return err
+}
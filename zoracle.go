@@ -2,38 +2,81 @@ package main
 
 import (
 	"context"
-	"net/url"
+	"fmt"
+	"net/http"
 	"time"
 	"regexp"
 
-	"git.zabbix.com/ap/plugin-support/uri"
 	"git.zabbix.com/ap/plugin-support/zbxerr"
 	"git.zabbix.com/ap/plugin-support/plugin"
+	"github.com/omeid/go-yarn"
 )
 
 const (
 	pluginName = "zoracle"
 	hkInterval = 10
 	sqlExt     = ".sql"
+
+	defaultPoolMaxSessions = 5
+	defaultPoolWaitTimeout = 5 * time.Second
+
+	defaultFanOutConcurrency = 4
+
+	defaultAuditLogMaxSize = 10
+
+	// stopTimeout is the time Stop waits for requests to return after their queries were broken.
+	stopTimeout = 5 * time.Second
 )
 
 // Plugin inherits plugin.Base and store plugin-specific data.
 type Plugin struct {
 	plugin.Base
-	connMgr *ConnManager
-	options PluginOptions
+	connMgr  *ConnManager
+	options  PluginOptions
+	redactor redactor
+	auditLog *auditLog
 }
 
 // impl is the pointer to the plugin implementation.
 var impl Plugin
 
 // Export implements the Exporter interface.
-func (p *Plugin) Export(key string, rawParams []string, _ plugin.ContextProvider) (result interface{}, err error) {
+func (p *Plugin) Export(key string, rawParams []string, ctx plugin.ContextProvider) (result interface{}, err error) {
+	var itemID uint64
+	if ctx != nil {
+		itemID = ctx.ItemID()
+	}
+
+	result, err = p.export(key, rawParams, itemID)
+
+	if err != nil && p.options.ErrorsAsValue == 1 && errorValueKeys[key] {
+		return errorValue(err, p.redactor.redact(err.Error()))
+	}
+
+	return result, err
+}
+
+// export collects the value of a key for an item, itemID is zero if the item is not known.
+// Export reports its errors as they are configured.
+func (p *Plugin) export(key string, rawParams []string, itemID uint64) (result interface{}, err error) {
+	var queryName string
+
+	// Stop may reset the plugin while the request is running, the request keeps to the manager and log it started with.
+	connMgr := p.connMgr
+	auditLog := p.auditLog
+	start := time.Now()
+
+	defer func() {
+		connMgr.stats.request(key, queryName, time.Since(start), err, isTimeout(err))
+	}()
+
 	
     p.Tracef("[Export] begin for key : %s", key)
 
 	//Sessions map[string]Session
-	params, extraParams, err := metrics[key].EvalParams(rawParams, p.options.Sessions)
+	sessionName, session := p.options.getSession(rawParams)
+	params, extraParams, err := metrics[key].EvalParams(rawParams, p.options.keySessions())
+	p.redactor.add(params["Password"])
 
 	p.Tracef("[Export] params : %s", params)
 	p.Tracef("[Export] extraParams : %s", extraParams)
@@ -41,39 +84,72 @@ func (p *Plugin) Export(key string, rawParams []string, _ plugin.ContextProvider
 		return nil, err
 	}
 
-	service := url.QueryEscape(params["Service"])
-	uri, err := uri.NewWithCreds(params["URI"]+"?service="+service, params["User"], params["Password"], uriDefaults)
+	if handlePlugin := getPluginHandlerFunc(key); handlePlugin != nil {
+		p.Tracef("[Export] executing plugin handler for key : %s", key)
+		return handlePlugin(p, itemID, params, extraParams...)
+	}
+
+	uri, opts, err := newConnParams(&p.options, params, sessionName, session)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if handleConn := getConnHandlerFunc(key); handleConn != nil {
+		p.Tracef("[Export] executing connection handler for key : %s", key)
+		handlerStart := time.Now()
+		result, err = handleConn(p, *uri, opts, params, extraParams...)
+
+		// Only the handlers of keys with a fixed SQL text reach the database, they do so once connected.
+		if _, ok := keySQL[key]; ok {
+			if conn := connMgr.get(*uri); conn != nil {
+				info := &requestInfo{session: uriLabel(*uri), stats: connMgr.stats}
+				p.audit(auditLog, key, info, conn.WhoAmI(), params, extraParams, time.Since(handlerStart), err)
+			}
+		}
+
+		return result, err
+	}
+
+	var query *namedQuery
+
+	if _, ok := params["Query"]; ok {
+		if query, err = connMgr.getQuery(params["Query"]); err != nil {
+			return nil, err
+		}
+
+		params["Query"] = query.text
+		queryName = query.name
+	}
+
 	handleMetric := getHandlerFunc(key)
 	
 	if handleMetric == nil {
 		return nil, zbxerr.ErrorUnsupportedMetric
 	}
 
+	if err = connMgr.begin(); err != nil {
+		return nil, err
+	}
+	defer connMgr.end()
+
 	p.Tracef("[Export] grab connection")
-	conn, err := p.connMgr.GetConnection(p, *uri)
+	connectStart := time.Now()
+	conn, err := connMgr.GetConnection(p, *uri, opts)
+	connectLatency := time.Since(connectStart)
+
 	if err != nil {
 		p.Tracef("[Export] error grabbing connection")
+
+		if key == keyPingDetail {
+			return p.pingFailureDetail(connectLatency, err)
+		}
+
 		// Special logic of processing connection errors should be used if oracle.ping is requested
 		// because it must return pingFailed if any error occurred.
 	
 		if key == keyPing {
-			p.Tracef("[Export] check if error containt ORA-XXXXX")
-			var rgx = regexp.MustCompile(`ORA-[0-9]{5}.*`)
-			rs := rgx.FindStringSubmatch(err.Error())
-
-			if len(rs) > 0 {
-				p.Tracef("[Export] found error ORA-XXXXX")
-				p.Tracef("[Export] returning -> %s", rs[0])
-				return rs[0], nil
-			}
-
-			p.Tracef("[Export] didn't found ORA-XXXXX so returning pingfailed")
-			return pingFailed, nil
+			return p.pingFailure(err), nil
 		}
 
         p.Tracef("[Export] returning error when key != KeyPing")		
@@ -81,14 +157,66 @@ func (p *Plugin) Export(key string, rawParams []string, _ plugin.ContextProvider
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(conn.ctx, conn.callTimeout)
+	// The most specific timeout wins: the query's, the session's (kept by the connection) or the plugin's.
+	callTimeout := conn.callTimeout
+	if query != nil && query.callTimeout > 0 {
+		callTimeout = query.callTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(conn.ctx, callTimeout)
 
 	defer cancel()
 
+	// The request gets a dedicated session, so the call can be broken on the server when the timeout expires.
+	sess, err := conn.openSession(ctx)
+	if err != nil {
+		err = connMgr.sessionError(*uri, conn, err)
+
+		if key == keyPingDetail {
+			return p.pingFailureDetail(connectLatency, err)
+		}
+
+		// The database refusing new sessions is a ping failure as much as refusing the connection.
+		if key == keyPing {
+			return p.pingFailure(err), nil
+		}
+
+		p.Errf(err.Error())
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, connectLatencyKey{}, connectLatency)
+
+	info := &requestInfo{session: uriLabel(*uri), stats: connMgr.stats}
+	if query != nil {
+		info.query = queryLabel(query)
+		info.name = query.name
+	}
+
+	ctx = context.WithValue(ctx, requestInfoKey{}, info)
+	ctx = withCallTraceTag(ctx, key, itemID, info.query)
+
+	stopWatch := sess.breakOnTimeout(ctx)
+	handlerStart := time.Now()
+
 	p.Tracef("[Export] executing handleMetric for key : %s", key)
-	result, err = handleMetric(p, ctx, conn, params, extraParams...)
+	result, err = handleMetric(p, ctx, sess, params, extraParams...)
 	p.Tracef("[Export] after executing handleMetric for key : %s", key)
 
+	stopWatch()
+
+	if sess.release() {
+		p.Warningf("session of %s discarded, it did not answer after the call was broken", uri.Addr())
+	}
+
+	// The error of a broken call may be anything the driver returned at that moment.
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		p.Debugf("[Export] call broken on timeout: %s", err.Error())
+		err = errorQueryTimeout.Wrap(fmt.Errorf("call timeout of %s exceeded", callTimeout))
+	}
+
+	p.audit(auditLog, key, info, conn.WhoAmI(), params, extraParams, time.Since(handlerStart), err)
+
 	if err != nil {
 		p.Errf(err.Error())
 		p.Tracef("[Export] finished with error!!! key : %s", key)
@@ -101,18 +229,66 @@ func (p *Plugin) Export(key string, rawParams []string, _ plugin.ContextProvider
 	return result, err
 }
 
+// pingFailure returns the value of zoracle.ping for a failed connection: the ORA-XXXXX error if there is one,
+// or pingFailed otherwise.
+func (p *Plugin) pingFailure(err error) interface{} {
+	p.Tracef("[Export] check if error containt ORA-XXXXX")
+	var rgx = regexp.MustCompile(`ORA-[0-9]{5}.*`)
+	rs := rgx.FindStringSubmatch(err.Error())
+
+	if len(rs) > 0 {
+		p.Tracef("[Export] found error ORA-XXXXX")
+		p.Tracef("[Export] returning -> %s", rs[0])
+		return rs[0]
+	}
+
+	p.Tracef("[Export] didn't found ORA-XXXXX so returning pingfailed")
+	return pingFailed
+}
+
 // Start implements the Runner interface and performs initialization when plugin is activated.
 func (p *Plugin) Start() {
+	queryStorage := yarn.NewFromMap(map[string]string{})
+
+	if p.options.CustomQueriesPath != "" {
+		var err error
+
+		queryStorage, err = yarn.New(http.Dir(p.options.CustomQueriesPath), "*"+sqlExt)
+		if err != nil {
+			p.Errf(err.Error())
+			// create empty storage if error occurred
+			queryStorage = yarn.NewFromMap(map[string]string{})
+		}
+	}
+
 	p.connMgr = NewConnManager(
 		time.Duration(p.options.KeepAlive)*time.Second,
 		time.Duration(p.options.ConnectTimeout)*time.Second,
 		time.Duration(p.options.CallTimeout)*time.Second,
 		hkInterval*time.Second,
+		time.Duration(p.options.SecretCacheTTL)*time.Second,
+		time.Duration(p.options.LoginBackoff)*time.Second,
+		p.options.LoginFailureThreshold,
+		queryStorage,
 	)
+
+	if p.options.AuditLog != "" {
+		p.auditLog = newAuditLog(p.options.AuditLog, int64(p.options.AuditLogMaxSize)*1024*1024)
+	}
+
+	if p.options.Warmup == 1 {
+		go p.warmup(p.connMgr)
+	}
 }
 
 // Stop implements the Runner interface and frees resources when plugin is deactivated.
+// In-flight queries are broken, so a config reload does not leave orphaned sessions in the database.
 func (p *Plugin) Stop() {
-	p.connMgr.Destroy()
+	p.connMgr.Destroy(stopTimeout)
 	p.connMgr = nil
+
+	if p.auditLog != nil {
+		p.auditLog.close()
+		p.auditLog = nil
+	}
 }